@@ -0,0 +1,182 @@
+package kv
+
+import (
+	"github.com/dgraph-io/badger/v4"
+	"github.com/pkg/errors"
+)
+
+// Entry 是批量写入中的一个键值对。
+type Entry[K, V any] struct {
+	Key   K
+	Value V
+}
+
+// Result 是批量读取中单个键的结果。
+type Result[V any] struct {
+	// Value 是获取到的值，键不存在时为零值。
+	Value V
+	// Exists 表示键是否存在。
+	Exists bool
+	// Err 是读取或解码这个键时发生的错误。
+	Err error
+}
+
+// MSet 批量将键值对存入默认 Store，可选择性地设置生存时间 (TTL)。
+// 写入通过 Badger 的 WriteBatch 完成，事务过大时会自动拆分提交。
+// K, V 是泛型参数，代表任意类型的键和值。
+// entries: 要写入的键值对。
+// ttl: 可选参数，生命周期，单位毫秒，作用于所有键。
+// 返回值:
+// []error: 与 entries 一一对应的错误，成功的键为 nil。
+func MSet[K, V any](entries []Entry[K, V], ttl ...int64) []error {
+	s, err := defaultStore()
+	if err != nil {
+		return fillErrors(len(entries), err)
+	}
+	return MSetStore(s, entries, ttl...)
+}
+
+// MSetStore 与 MSet 相同，但作用于指定的 Store。
+func MSetStore[K, V any](s *Store, entries []Entry[K, V], ttl ...int64) []error {
+	errs := make([]error, len(entries))
+	wb := s.db.NewWriteBatch()
+	defer wb.Cancel()
+
+	// written 记录已经加入批次的条目下标，批次提交失败时将错误分配给它们。
+	written := make([]int, 0, len(entries))
+	for i, e := range entries {
+		k, err := serializeKey(e.Key)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		v, meta, err := s.marshal(k, e.Value)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		if err = wb.SetEntry(newEntry(k, v, meta, ttl)); err != nil {
+			errs[i] = err
+			// WriteBatch 出错后不能继续使用，剩余的条目都标记为失败。
+			for j := i + 1; j < len(entries); j++ {
+				errs[j] = err
+			}
+			break
+		}
+		written = append(written, i)
+	}
+
+	if err := wb.Flush(); err != nil {
+		for _, i := range written {
+			errs[i] = err
+		}
+	}
+	return errs
+}
+
+// MGet 在同一个只读事务中批量获取默认 Store 中的值。
+// K, V 是泛型参数，代表任意类型的键和值。
+// keys: 要获取的键。
+// 返回值:
+// []Result[V]: 与 keys 一一对应的结果。
+func MGet[K, V any](keys []K) []Result[V] {
+	s, err := defaultStore()
+	if err != nil {
+		results := make([]Result[V], len(keys))
+		for i := range results {
+			results[i].Err = err
+		}
+		return results
+	}
+	return MGetStore[K, V](s, keys)
+}
+
+// MGetStore 与 MGet 相同，但作用于指定的 Store。
+func MGetStore[K, V any](s *Store, keys []K) []Result[V] {
+	results := make([]Result[V], len(keys))
+	err := s.db.View(func(txn *badger.Txn) error {
+		for i, key := range keys {
+			r := &results[i]
+			k, err := serializeKey(key)
+			if err != nil {
+				r.Err = err
+				continue
+			}
+			item, err := txn.Get(k)
+			if err != nil {
+				if !errors.Is(err, badger.ErrKeyNotFound) {
+					r.Err = err
+				}
+				continue
+			}
+			r.Exists = true
+			r.Err = item.Value(func(val []byte) error {
+				if r.Value, err = unmarshalValue[V](s, item, val); err != nil {
+					return err
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		for i := range results {
+			results[i].Err = err
+		}
+	}
+	return results
+}
+
+// MDel 批量从默认 Store 中删除键。
+// 删除通过 Badger 的 WriteBatch 完成，事务过大时会自动拆分提交。
+// K 是泛型参数，代表任意类型的键。
+// keys: 要删除的键。
+// 返回值:
+// []error: 与 keys 一一对应的错误，成功的键为 nil。
+func MDel[K any](keys []K) []error {
+	s, err := defaultStore()
+	if err != nil {
+		return fillErrors(len(keys), err)
+	}
+	return MDelStore(s, keys)
+}
+
+// MDelStore 与 MDel 相同，但作用于指定的 Store。
+func MDelStore[K any](s *Store, keys []K) []error {
+	errs := make([]error, len(keys))
+	wb := s.db.NewWriteBatch()
+	defer wb.Cancel()
+
+	written := make([]int, 0, len(keys))
+	for i, key := range keys {
+		k, err := serializeKey(key)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		if err = wb.Delete(k); err != nil {
+			errs[i] = err
+			for j := i + 1; j < len(keys); j++ {
+				errs[j] = err
+			}
+			break
+		}
+		written = append(written, i)
+	}
+
+	if err := wb.Flush(); err != nil {
+		for _, i := range written {
+			errs[i] = err
+		}
+	}
+	return errs
+}
+
+// fillErrors 返回一个长度为 n、所有元素都是 err 的错误切片。
+func fillErrors(n int, err error) []error {
+	errs := make([]error, n)
+	for i := range errs {
+		errs[i] = err
+	}
+	return errs
+}
@@ -0,0 +1,50 @@
+package kv
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMSetMGetMDel(t *testing.T) {
+	s, err := Open(Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	entries := make([]Entry[string, int64], 1000)
+	for i := range entries {
+		entries[i] = Entry[string, int64]{Key: fmt.Sprintf("batch:%04d", i), Value: int64(i)}
+	}
+	for i, err := range MSetStore(s, entries) {
+		if err != nil {
+			t.Fatalf("MSet() error for %d = %v", i, err)
+		}
+	}
+
+	keys := []string{"batch:0000", "batch:0999", "batch:missing"}
+	results := MGetStore[string, int64](s, keys)
+	if len(results) != len(keys) {
+		t.Fatalf("MGet() len = %d, want %d", len(results), len(keys))
+	}
+	if r := results[0]; r.Err != nil || !r.Exists || r.Value != 0 {
+		t.Errorf("MGet()[0] = %+v, want value 0", r)
+	}
+	if r := results[1]; r.Err != nil || !r.Exists || r.Value != 999 {
+		t.Errorf("MGet()[1] = %+v, want value 999", r)
+	}
+	if r := results[2]; r.Err != nil || r.Exists {
+		t.Errorf("MGet()[2] = %+v, want missing", r)
+	}
+
+	for i, err := range MDelStore(s, keys[:2]) {
+		if err != nil {
+			t.Fatalf("MDel() error for %d = %v", i, err)
+		}
+	}
+	for i, r := range MGetStore[string, int64](s, keys[:2]) {
+		if r.Exists {
+			t.Errorf("MGet()[%d] key should be deleted", i)
+		}
+	}
+}
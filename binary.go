@@ -11,8 +11,10 @@ import (
 )
 
 // serialize 函数将任意类型的数据序列化为字节切片。
+// 实现了 Marshaler 的类型直接调用 MarshalKV。
 // 对于 Go 的基础类型（如 int, float, bool, string），它使用 `encoding/binary` 进行高效的二进制编码。
-// 对于其他复杂类型（如 struct, slice, map），它回退到使用 `encoding/gob` 进行编码。
+// 对于常见的切片、数组、map 和 time.Time，它使用 serializeNative 中的紧凑编码。
+// 对于其他复杂类型（如 struct），它回退到使用 `encoding/gob` 进行编码。
 // 这种方法旨在为常用类型提供高性能的序列化，同时保持对复杂类型的通用支持。
 // T 是泛型参数，代表任意类型的数据。
 // data: 需要被序列化的数据。
@@ -20,6 +22,21 @@ import (
 // []byte: 序列化后的字节切片。
 // error: 序列化过程中发生的任何错误。
 func serialize[T any](data T) ([]byte, error) {
+	// 实现了 Marshaler 的类型（通常由 kvgen 生成）不使用反射。
+	if result, ok, err := marshalKV(data); ok {
+		return result, err
+	}
+	return serializeValue(data, true)
+}
+
+// serializeLegacy 按旧版本的格式序列化数据：基础类型使用二进制编码，其他类型一律使用 gob。
+// 键始终使用这种格式，以保持已有数据库中键的编码不变。
+func serializeLegacy(data any) ([]byte, error) {
+	return serializeValue(data, false)
+}
+
+// serializeValue 是 serialize 和 serializeLegacy 的共同实现，native 表示是否使用 serializeNative 的编码。
+func serializeValue(data any, native bool) ([]byte, error) {
 	var buf bytes.Buffer
 	v := reflect.ValueOf(data)
 	k := v.Kind()
@@ -83,7 +100,12 @@ func serialize[T any](data T) ([]byte, error) {
 		binary.BigEndian.PutUint64(result[0:8], float64Bits(real(c)))
 		binary.BigEndian.PutUint64(result[8:16], float64Bits(imag(c)))
 	default:
-		// 对于非基础类型，使用 gob 进行编码。
+		if native {
+			if result, ok, err := serializeNative(v); ok {
+				return result, err
+			}
+		}
+		// 对于其他非基础类型，使用 gob 进行编码。
 		enc := gob.NewEncoder(&buf)
 		if err := enc.Encode(data); err != nil {
 			return nil, errors.Wrap(err, "encode error")
@@ -107,116 +129,158 @@ func deserialize[T any](data []byte) (T, error) {
 		return result, nil // nil 数据反序列化为零值
 	}
 
+	if u, ok := any(&result).(Unmarshaler); ok {
+		err := u.UnmarshalKV(data)
+		return result, err
+	}
+
 	t := reflect.TypeOf(result)
-	k := t.Kind()
+	// 接口类型没有具体的 reflect.Type，无法确定解码方式。
+	if t == nil {
+		return result, errors.New("cannot deserialize into an interface type")
+	}
+	value, err := deserializeType(data, t, false)
+	if err != nil {
+		return result, err
+	}
+	result = value.Interface().(T)
+	return result, nil
+}
+
+// deserializeType 将字节切片反序列化为类型 t 的 reflect.Value。
+// 它供无法在编译期确定类型的调用方（例如 Store 的方法）使用。
+// strict 为 true 时，定长类型要求数据长度完全一致，gob 要求消费全部数据。
+func deserializeType(data []byte, t reflect.Type, strict bool) (reflect.Value, error) {
+	return deserializeFormat(data, t, strict, true)
+}
+
+// deserializeLegacy 与 deserializeType 相同，但按 serializeLegacy 的格式解码：非基础类型一律使用 gob。
+func deserializeLegacy(data []byte, t reflect.Type, strict bool) (reflect.Value, error) {
+	return deserializeFormat(data, t, strict, false)
+}
+
+// deserializeFormat 是 deserializeType 和 deserializeLegacy 的共同实现，native 的含义同 serializeValue。
+func deserializeFormat(data []byte, t reflect.Type, strict, native bool) (reflect.Value, error) {
+	if data == nil {
+		return reflect.New(t).Elem(), nil // nil 数据反序列化为零值
+	}
 
 	// 如果目标类型是指针，则先反序列化为元素类型，然后创建一个新的指针。
-	if k == reflect.Ptr {
+	if t.Kind() == reflect.Ptr {
 		elemType := t.Elem()
-		elemValue, err := deserializeValue(data, elemType)
+		elemValue, err := deserializeValue(data, elemType, strict, native)
 		if err != nil {
-			return result, err
+			return reflect.Value{}, err
 		}
 		ptr := reflect.New(elemType)
 		ptr.Elem().Set(elemValue)
-		result = ptr.Interface().(T)
-		return result, nil
+		return ptr, nil
 	}
 
 	// 对于非指针类型，直接反序列化。
-	value, err := deserializeValue(data, t)
-	if err != nil {
-		return result, err
-	}
-	result = value.Interface().(T)
-	return result, nil
+	return deserializeValue(data, t, strict, native)
 }
 
 // deserializeValue 是反序列化的核心辅助函数。
 // 它根据提供的 reflect.Type 将字节切片解码为 reflect.Value。
-func deserializeValue(data []byte, t reflect.Type) (reflect.Value, error) {
+// strict 的含义同 deserializeType，native 的含义同 serializeValue。
+func deserializeValue(data []byte, t reflect.Type, strict, native bool) (reflect.Value, error) {
+	if native && reflect.PointerTo(t).Implements(unmarshalerType) {
+		ptr := reflect.New(t)
+		if err := ptr.Interface().(Unmarshaler).UnmarshalKV(data); err != nil {
+			return ptr.Elem(), errors.Wrap(err, "decode error")
+		}
+		return ptr.Elem(), nil
+	}
+
 	k := t.Kind()
 	value := reflect.New(t).Elem()
+	if size := fixedSize(k); strict && size > 0 && len(data) != size {
+		return value, errors.Wrapf(errInvalidLength, "%d bytes for %s", len(data), t)
+	}
 
 	// 根据目标类型进行不同的反序列化处理。
 	switch k {
 	case reflect.Bool:
 		if len(data) < 1 {
-			return value, errors.New("invalid bool data")
+			return value, errors.Wrap(errInvalidLength, "invalid bool data")
 		}
 		value.SetBool(data[0] != 0)
 	case reflect.Int8:
 		if len(data) < 1 {
-			return value, errors.New("insufficient data for int8")
+			return value, errors.Wrap(errInvalidLength, "insufficient data for int8")
 		}
 		value.SetInt(int64(int8(data[0])))
 	case reflect.Uint8:
 		if len(data) < 1 {
-			return value, errors.New("insufficient data for uint8")
+			return value, errors.Wrap(errInvalidLength, "insufficient data for uint8")
 		}
 		value.SetUint(uint64(data[0]))
 	case reflect.Int16:
 		if len(data) < 2 {
-			return value, errors.New("insufficient data for int16")
+			return value, errors.Wrap(errInvalidLength, "insufficient data for int16")
 		}
 		value.SetInt(int64(int16(binary.BigEndian.Uint16(data))))
 	case reflect.Uint16:
 		if len(data) < 2 {
-			return value, errors.New("insufficient data for uint16")
+			return value, errors.Wrap(errInvalidLength, "insufficient data for uint16")
 		}
 		value.SetUint(uint64(binary.BigEndian.Uint16(data)))
 	case reflect.Int32:
 		if len(data) < 4 {
-			return value, errors.New("insufficient data for int32")
+			return value, errors.Wrap(errInvalidLength, "insufficient data for int32")
 		}
 		value.SetInt(int64(int32(binary.BigEndian.Uint32(data))))
 	case reflect.Uint32:
 		if len(data) < 4 {
-			return value, errors.New("insufficient data for uint32")
+			return value, errors.Wrap(errInvalidLength, "insufficient data for uint32")
 		}
 		value.SetUint(uint64(binary.BigEndian.Uint32(data)))
 	case reflect.Int, reflect.Int64:
 		if len(data) < 8 {
-			return value, errors.New("insufficient data for int64")
+			return value, errors.Wrap(errInvalidLength, "insufficient data for int64")
 		}
 		value.SetInt(int64(binary.BigEndian.Uint64(data)))
 	case reflect.Uint, reflect.Uint64:
 		if len(data) < 8 {
-			return value, errors.New("insufficient data for uint64")
+			return value, errors.Wrap(errInvalidLength, "insufficient data for uint64")
 		}
 		value.SetUint(binary.BigEndian.Uint64(data))
 	case reflect.Float32:
 		if len(data) < 4 {
-			return value, errors.New("insufficient data for float32")
+			return value, errors.Wrap(errInvalidLength, "insufficient data for float32")
 		}
 		value.SetFloat(float64(float32FromBits(binary.BigEndian.Uint32(data))))
 	case reflect.Float64:
 		if len(data) < 8 {
-			return value, errors.New("insufficient data for float64")
+			return value, errors.Wrap(errInvalidLength, "insufficient data for float64")
 		}
 		value.SetFloat(float64FromBits(binary.BigEndian.Uint64(data)))
 	case reflect.String:
 		value.SetString(string(data))
 	case reflect.Complex64:
 		if len(data) < 8 {
-			return value, errors.New("insufficient data for complex64")
+			return value, errors.Wrap(errInvalidLength, "insufficient data for complex64")
 		}
 		real_ := float32FromBits(binary.BigEndian.Uint32(data[0:4]))
 		imag_ := float32FromBits(binary.BigEndian.Uint32(data[4:8]))
 		value.SetComplex(complex128(complex(real_, imag_)))
 	case reflect.Complex128:
 		if len(data) < 16 {
-			return value, errors.New("insufficient data for complex128")
+			return value, errors.Wrap(errInvalidLength, "insufficient data for complex128")
 		}
 		real_ := float64FromBits(binary.BigEndian.Uint64(data[0:8]))
 		imag_ := float64FromBits(binary.BigEndian.Uint64(data[8:16]))
 		value.SetComplex(complex(real_, imag_))
 	default:
-		// 对于非基础类型，使用 gob 进行解码。
-		buf := bytes.NewBuffer(data)
-		dec := gob.NewDecoder(buf)
-		if err := dec.Decode(value.Addr().Interface()); err != nil {
-			return value, errors.Wrap(err, "decode error")
+		if native {
+			if result, ok, err := deserializeNative(data, t); ok {
+				return result, err
+			}
+		}
+		// 对于其他非基础类型，使用 gob 进行解码。
+		if err := gobDecode(data, value.Addr().Interface(), strict); err != nil {
+			return value, err
 		}
 	}
 	return value, nil
@@ -0,0 +1,472 @@
+/*
+kvgen 为带有 //kvgen:marshal 注释的结构体生成 MarshalKV 和 UnmarshalKV 方法，
+使这些类型在 kv 中的读写不再依赖反射和 gob。
+
+用法：在包中任意文件里加入
+
+	//go:generate go run github.com/clong1995/go-db-kv/cmd/kvgen
+
+并在结构体的文档注释中加入 //kvgen:marshal：
+
+	//kvgen:marshal
+	type User struct {
+		ID   int64
+		Name string
+	}
+
+kvgen 读取当前目录下的所有 Go 文件，将生成的代码写入 kv_gen.go。
+
+支持的字段类型：bool、各种整数和浮点数、string、[]byte、time.Time、time.Duration、
+同一个包中同样带有注释的结构体，以及以上类型的切片。标签为 `kv:"-"` 的字段被忽略。
+字段按声明顺序编码，增删或调整字段后需要重新生成，并且旧数据无法再被读取。
+*/
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// annotation 是标记需要生成代码的结构体的注释。
+const annotation = "//kvgen:marshal"
+
+func main() {
+	dir := flag.String("dir", ".", "包所在的目录")
+	output := flag.String("output", "kv_gen.go", "生成的文件名，相对于 -dir")
+	tests := flag.Bool("tests", false, "同时读取 _test.go 文件中的结构体")
+	flag.Parse()
+
+	src, err := generate(*dir, *output, *tests)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "kvgen:", err)
+		os.Exit(1)
+	}
+	if err = os.WriteFile(filepath.Join(*dir, *output), src, 0o644); err != nil {
+		fmt.Fprintln(os.Stderr, "kvgen:", err)
+		os.Exit(1)
+	}
+}
+
+// generate 解析 dir 中的 Go 文件，返回格式化后的生成代码。
+// output 文件本身不参与解析。tests 为 true 时包括 _test.go 文件。
+func generate(dir, output string, tests bool) ([]byte, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	g := &generator{fset: fset, structs: map[string]*ast.StructType{}}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".go") || name == output {
+			continue
+		}
+		if !tests && strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		// 外部测试包与被测包不在同一个包中，不能为其类型定义方法。
+		if strings.HasSuffix(file.Name.Name, "_test") {
+			continue
+		}
+		g.pkg = file.Name.Name
+		g.collect(file)
+	}
+	if len(g.names) == 0 {
+		return nil, fmt.Errorf("no struct annotated with %s in %s", annotation, dir)
+	}
+	sort.Strings(g.names)
+	return g.render()
+}
+
+// generator 保存一次生成过程的状态。
+type generator struct {
+	fset    *token.FileSet
+	pkg     string
+	names   []string
+	structs map[string]*ast.StructType
+	buf     bytes.Buffer
+	math    bool
+	time    bool
+	depth   int
+}
+
+// collect 收集文件中带有注释的结构体。
+func (g *generator) collect(file *ast.File) {
+	for _, decl := range file.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			ts := spec.(*ast.TypeSpec)
+			st, ok := ts.Type.(*ast.StructType)
+			if !ok || ts.TypeParams != nil {
+				continue
+			}
+			doc := ts.Doc
+			if doc == nil && len(gd.Specs) == 1 {
+				doc = gd.Doc
+			}
+			if annotated(doc) {
+				g.names = append(g.names, ts.Name.Name)
+				g.structs[ts.Name.Name] = st
+			}
+		}
+	}
+}
+
+// annotated 判断注释组中是否含有 annotation。
+func annotated(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, c := range doc.List {
+		if strings.TrimSpace(c.Text) == annotation {
+			return true
+		}
+	}
+	return false
+}
+
+// field 是一个需要编码的字段。
+type field struct {
+	name string
+	typ  ast.Expr
+}
+
+// fields 返回结构体中需要编码的字段，按声明顺序排列。
+func (g *generator) fields(st *ast.StructType) []field {
+	var fs []field
+	for _, f := range st.Fields.List {
+		if f.Tag != nil {
+			tag, _ := strconv.Unquote(f.Tag.Value)
+			if reflect.StructTag(tag).Get("kv") == "-" {
+				continue
+			}
+		}
+		if len(f.Names) == 0 {
+			// 嵌入字段以类型名作为字段名。
+			if id, ok := f.Type.(*ast.Ident); ok {
+				fs = append(fs, field{name: id.Name, typ: f.Type})
+				continue
+			}
+		}
+		for _, n := range f.Names {
+			if n.Name != "_" {
+				fs = append(fs, field{name: n.Name, typ: f.Type})
+			}
+		}
+	}
+	return fs
+}
+
+// render 生成所有方法和辅助代码，并格式化。
+func (g *generator) render() ([]byte, error) {
+	var body bytes.Buffer
+	for _, name := range g.names {
+		g.buf.Reset()
+		if err := g.marshal(name); err != nil {
+			return nil, err
+		}
+		if err := g.unmarshal(name); err != nil {
+			return nil, err
+		}
+		body.Write(g.buf.Bytes())
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by kvgen. DO NOT EDIT.\n\npackage %s\n\nimport (\n", g.pkg)
+	fmt.Fprintln(&out, `"encoding/binary"`)
+	fmt.Fprintln(&out, `"errors"`)
+	fmt.Fprintln(&out, `"io"`)
+	if g.math {
+		fmt.Fprintln(&out, `"math"`)
+	}
+	if g.time {
+		fmt.Fprintln(&out, `"time"`)
+	}
+	fmt.Fprintln(&out, ")")
+	out.Write(body.Bytes())
+	out.WriteString(helpers)
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated code: %v", err)
+	}
+	return src, nil
+}
+
+func (g *generator) printf(format string, args ...any) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// marshal 生成 MarshalKV 方法。
+func (g *generator) marshal(name string) error {
+	g.printf("\n// MarshalKV 实现 kv.Marshaler。\n")
+	g.printf("func (x %s) MarshalKV() ([]byte, error) {\n", name)
+	g.printf("var b []byte\n")
+	for _, f := range g.fields(g.structs[name]) {
+		if err := g.encode("x."+f.name, f.typ); err != nil {
+			return fmt.Errorf("%s.%s: %v", name, f.name, err)
+		}
+	}
+	g.printf("return b, nil\n}\n")
+	return nil
+}
+
+// unmarshal 生成 UnmarshalKV 方法。
+func (g *generator) unmarshal(name string) error {
+	g.printf("\n// UnmarshalKV 实现 kv.Unmarshaler。\n")
+	g.printf("func (x *%s) UnmarshalKV(data []byte) error {\n", name)
+	g.printf("r := &kvgenReader{data: data}\n")
+	for _, f := range g.fields(g.structs[name]) {
+		if err := g.decode("x."+f.name, f.typ); err != nil {
+			return fmt.Errorf("%s.%s: %v", name, f.name, err)
+		}
+	}
+	g.printf("return r.done()\n}\n")
+	return nil
+}
+
+// fixed 返回定长类型的字节数，以及编码和解码时使用的表达式模板。
+// 模板中的 %s 分别代表值和读取到的整数。
+func fixed(name string) (size int, enc, dec string) {
+	switch name {
+	case "bool":
+		return 1, "", ""
+	case "int8", "uint8", "byte":
+		return 1, "byte(%s)", "%s"
+	case "int16", "uint16":
+		return 2, "uint16(%s)", "%s"
+	case "int32", "uint32", "rune":
+		return 4, "uint32(%s)", "%s"
+	case "float32":
+		return 4, "math.Float32bits(%s)", "math.Float32frombits(%s)"
+	case "int", "int64", "uint", "uint64", "uintptr", "time.Duration":
+		return 8, "uint64(%s)", "%s"
+	case "float64":
+		return 8, "math.Float64bits(%s)", "math.Float64frombits(%s)"
+	}
+	return 0, "", ""
+}
+
+// typeName 返回类型表达式的源码形式。
+func (g *generator) typeName(typ ast.Expr) string {
+	var buf bytes.Buffer
+	_ = format.Node(&buf, g.fset, typ)
+	return buf.String()
+}
+
+// encode 生成将表达式 x 追加到 b 的代码。
+func (g *generator) encode(x string, typ ast.Expr) error {
+	name := g.typeName(typ)
+	if size, enc, _ := fixed(name); size > 0 {
+		switch {
+		case name == "bool":
+			g.printf("b = kvgenAppendBool(b, %s)\n", x)
+		case size == 1:
+			g.printf("b = append(b, %s)\n", fmt.Sprintf(enc, x))
+		default:
+			g.printf("b = binary.BigEndian.AppendUint%d(b, %s)\n", size*8, fmt.Sprintf(enc, x))
+		}
+		g.math = g.math || strings.HasPrefix(enc, "math.")
+		return nil
+	}
+	switch name {
+	case "string", "[]byte":
+		g.printf("b = kvgenAppendBytes(b, []byte(%s))\n", x)
+		return nil
+	case "time.Time":
+		return g.encodeMethod(x, "MarshalBinary")
+	}
+	if _, ok := g.structs[name]; ok {
+		return g.encodeMethod(x, "MarshalKV")
+	}
+	if at, ok := typ.(*ast.ArrayType); ok && at.Len == nil {
+		e := g.elemVar("e")
+		g.printf("b = binary.AppendUvarint(b, uint64(len(%s)))\n", x)
+		g.printf("for _, %s := range %s {\n", e, x)
+		if err := g.encode(e, at.Elt); err != nil {
+			return err
+		}
+		g.printf("}\n")
+		g.depth--
+		return nil
+	}
+	return fmt.Errorf("unsupported type %s", name)
+}
+
+// encodeMethod 生成调用 x 的编码方法并追加带长度前缀的结果的代码。
+func (g *generator) encodeMethod(x, method string) error {
+	g.printf("{\nv, err := %s.%s()\nif err != nil {\nreturn nil, err\n}\n", x, method)
+	g.printf("b = kvgenAppendBytes(b, v)\n}\n")
+	return nil
+}
+
+// decode 生成从 r 读取值并赋给 x 的代码。
+func (g *generator) decode(x string, typ ast.Expr) error {
+	name := g.typeName(typ)
+	if size, _, dec := fixed(name); size > 0 {
+		switch {
+		case name == "bool":
+			g.printf("%s = r.u8() != 0\n", x)
+		default:
+			read := fmt.Sprintf("r.u%d()", size*8)
+			if dec != "%s" {
+				g.printf("%s = %s\n", x, fmt.Sprintf(dec, read))
+			} else {
+				g.printf("%s = %s(%s)\n", x, name, read)
+			}
+			g.time = g.time || strings.HasPrefix(name, "time.")
+		}
+		return nil
+	}
+	switch name {
+	case "string":
+		g.printf("%s = string(r.bytes())\n", x)
+		return nil
+	case "[]byte":
+		g.printf("%s = r.copyBytes()\n", x)
+		return nil
+	case "time.Time":
+		return g.decodeMethod(x, "UnmarshalBinary")
+	}
+	if _, ok := g.structs[name]; ok {
+		return g.decodeMethod(x, "UnmarshalKV")
+	}
+	if at, ok := typ.(*ast.ArrayType); ok && at.Len == nil {
+		i := g.elemVar("i")
+		g.printf("if n := r.count(); n > 0 {\n%s = make(%s, n)\n", x, name)
+		g.printf("for %s := range %s {\n", i, x)
+		if err := g.decode(fmt.Sprintf("%s[%s]", x, i), at.Elt); err != nil {
+			return err
+		}
+		g.printf("}\n} else {\n%s = nil\n}\n", x)
+		g.depth--
+		return nil
+	}
+	return fmt.Errorf("unsupported type %s", name)
+}
+
+// decodeMethod 生成读取带长度前缀的数据并调用 x 的解码方法的代码。
+func (g *generator) decodeMethod(x, method string) error {
+	g.printf("if v := r.bytes(); r.err == nil {\nif err := %s.%s(v); err != nil {\nreturn err\n}\n}\n", x, method)
+	return nil
+}
+
+// elemVar 返回嵌套切片循环中使用的变量名，并增加嵌套深度。
+func (g *generator) elemVar(prefix string) string {
+	g.depth++
+	return fmt.Sprintf("%s%d", prefix, g.depth)
+}
+
+// helpers 是所有生成的方法共用的辅助代码。
+const helpers = `
+func kvgenAppendBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, 1)
+	}
+	return append(b, 0)
+}
+
+func kvgenAppendBytes(b, v []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// kvgenReader 按顺序读取编码后的字段，遇到错误后的读取都返回零值。
+type kvgenReader struct {
+	data []byte
+	err  error
+}
+
+func (r *kvgenReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.data) < n {
+		r.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *kvgenReader) u8() uint8 {
+	if b := r.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *kvgenReader) u16() uint16 {
+	if b := r.next(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *kvgenReader) u32() uint32 {
+	if b := r.next(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *kvgenReader) u64() uint64 {
+	if b := r.next(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+// count 读取切片长度。每个元素至少占一个字节，因此长度不能超过剩余的数据。
+func (r *kvgenReader) count() int {
+	if r.err != nil {
+		return 0
+	}
+	n, size := binary.Uvarint(r.data)
+	if size <= 0 || n > uint64(len(r.data)-size) {
+		r.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	r.data = r.data[size:]
+	return int(n)
+}
+
+func (r *kvgenReader) bytes() []byte {
+	n := r.count()
+	return r.next(n)
+}
+
+func (r *kvgenReader) copyBytes() []byte {
+	b := r.bytes()
+	if len(b) == 0 {
+		return nil
+	}
+	return append([]byte(nil), b...)
+}
+
+func (r *kvgenReader) done() error {
+	if r.err == nil && len(r.data) > 0 {
+		return errors.New("kvgen: unexpected trailing data")
+	}
+	return r.err
+}
+`
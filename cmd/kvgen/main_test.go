@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeSource(t *testing.T, src string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "types.go"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestGenerate(t *testing.T) {
+	dir := writeSource(t, `package demo
+
+//kvgen:marshal
+type Point struct {
+	X, Y int32
+	Labels []string
+	skip string `+"`kv:\"-\"`"+`
+}
+
+type Ignored struct{ A int }
+`)
+	src, err := generate(dir, "kv_gen.go", false)
+	if err != nil {
+		t.Fatalf("generate() error = %v", err)
+	}
+	out := string(src)
+	for _, want := range []string{"package demo", "func (x Point) MarshalKV()", "func (x *Point) UnmarshalKV(", "x.Labels"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("generated code is missing %q", want)
+		}
+	}
+	for _, unwanted := range []string{"Ignored", "x.skip", `"math"`, `"time"`} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("generated code should not contain %q", unwanted)
+		}
+	}
+}
+
+func TestGenerate_Errors(t *testing.T) {
+	dir := writeSource(t, "package demo\n\n//kvgen:marshal\ntype Bad struct {\n\tM map[string]int\n}\n")
+	if _, err := generate(dir, "kv_gen.go", false); err == nil || !strings.Contains(err.Error(), "Bad.M") {
+		t.Errorf("generate() error = %v, want unsupported type error for Bad.M", err)
+	}
+
+	dir = writeSource(t, "package demo\n\ntype Plain struct{}\n")
+	if _, err := generate(dir, "kv_gen.go", false); err == nil {
+		t.Error("generate() without annotated structs should fail")
+	}
+}
@@ -0,0 +1,226 @@
+package kv
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/gob"
+	"encoding/json"
+	"reflect"
+
+	"github.com/pkg/errors"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec 负责值的编码与解码。键的编码不受 Codec 影响，始终使用保序的 serializeKey。
+// 可以通过 Options.Codec 为整个 Store 指定，也可以通过 WithCodec 为单次调用指定。
+type Codec interface {
+	// Marshal 将 v 编码为字节切片。
+	Marshal(v any) ([]byte, error)
+	// Unmarshal 将 data 解码到 v 指向的变量，v 必须是非 nil 指针。
+	Unmarshal(data []byte, v any) error
+}
+
+// 内置的编解码器。
+var (
+	// HybridCodec 是默认的编解码器：基础类型和常见的切片、map、time.Time 使用二进制编码，其他类型使用 gob。
+	// 实现了 Marshaler、proto.Message 或 encoding.BinaryMarshaler 的类型使用它们自己的编码。
+	HybridCodec Codec = hybridCodec{}
+	// JSONCodec 使用 encoding/json，便于其他语言的工具读取。
+	JSONCodec Codec = jsonCodec{}
+	// GobCodec 对所有类型使用 encoding/gob。
+	GobCodec Codec = gobCodec{}
+	// MsgpackCodec 使用 MessagePack，体积比 JSON 小且跨语言。
+	MsgpackCodec Codec = msgpackCodec{}
+)
+
+// codecs 是配置项 "CACHE CODEC" 可以选择的编解码器。
+var codecs = map[string]Codec{
+	"hybrid":  HybridCodec,
+	"json":    JSONCodec,
+	"gob":     GobCodec,
+	"msgpack": MsgpackCodec,
+}
+
+// WithCodec 返回一个使用 codec 编解码值的默认 Store 视图。
+// 视图与默认 Store 共享同一个数据库，可用于单次调用，例如 kv.WithCodec(kv.JSONCodec) 之后调用其方法。
+func WithCodec(codec Codec) (*Store, error) {
+	s, err := defaultStore()
+	if err != nil {
+		return nil, err
+	}
+	return s.WithCodec(codec), nil
+}
+
+// WithCodec 返回一个使用 codec 编解码值的 Store 视图，视图与 s 共享同一个数据库。
+// 关闭视图等同于关闭 s。
+func (s *Store) WithCodec(codec Codec) *Store {
+	view := *s
+	view.codec = s.strictCodec(codec)
+	return &view
+}
+
+// hybridCodec 是基于 serialize 和 deserializeType 的编解码器。
+type hybridCodec struct {
+	// strict 为 true 时拒绝长度不符或带有多余字节的数据，参见 Options.StrictDecode。
+	strict bool
+	// legacy 为 true 时使用旧版本的格式，用于读取没有 metaNative 标记的值。
+	legacy bool
+}
+
+func (c hybridCodec) Marshal(v any) ([]byte, error) {
+	// nil 指针序列化为 nil，避免调用其方法。
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return nil, nil
+	}
+	if c.legacy {
+		return serializeLegacy(v)
+	}
+	switch m := v.(type) {
+	case Marshaler:
+		return m.MarshalKV()
+	case proto.Message:
+		return proto.Marshal(m)
+	case encoding.BinaryMarshaler:
+		if !isPrimitive(reflect.TypeOf(v)) {
+			return m.MarshalBinary()
+		}
+	}
+	return serialize(v)
+}
+
+func (c hybridCodec) Unmarshal(data []byte, v any) error {
+	out := reflect.ValueOf(v)
+	if out.Kind() != reflect.Ptr || out.IsNil() {
+		return errors.New("value must be a non-nil pointer")
+	}
+	if c.legacy {
+		value, err := deserializeLegacy(data, out.Elem().Type(), c.strict)
+		if err != nil {
+			return err
+		}
+		out.Elem().Set(value)
+		return nil
+	}
+	if u, ok := v.(Unmarshaler); ok {
+		if err := u.UnmarshalKV(data); err != nil {
+			return errors.Wrap(err, "decode error")
+		}
+		return nil
+	}
+	t := out.Elem().Type()
+
+	// 如果目标类型是指针，则为其分配元素，使 proto.Message 和 BinaryUnmarshaler 可以直接解码。
+	alloc := t.Kind() == reflect.Ptr
+	target := out
+	if alloc {
+		target = reflect.New(t.Elem())
+	}
+	switch m := target.Interface().(type) {
+	case Unmarshaler:
+		// 由下面的 deserializeType 处理。
+	case proto.Message:
+		if err := proto.Unmarshal(data, m); err != nil {
+			return errors.Wrap(err, "decode error")
+		}
+		if alloc {
+			out.Elem().Set(target)
+		}
+		return nil
+	case encoding.BinaryUnmarshaler:
+		if !isPrimitive(target.Elem().Type()) {
+			if err := m.UnmarshalBinary(data); err != nil {
+				return errors.Wrap(err, "decode error")
+			}
+			if alloc {
+				out.Elem().Set(target)
+			}
+			return nil
+		}
+	}
+
+	value, err := deserializeType(data, t, c.strict)
+	if err != nil {
+		return err
+	}
+	out.Elem().Set(value)
+	return nil
+}
+
+// formatCodec 返回读取 UserMeta 为 meta 的值时使用的编解码器：
+// 没有 metaNative 标记的值由旧版本写入，HybridCodec 需要按旧版本的格式解码。
+func formatCodec(c Codec, meta byte) Codec {
+	if hc, ok := c.(hybridCodec); ok && meta&metaNative == 0 {
+		hc.legacy = true
+		return hc
+	}
+	return c
+}
+
+// isPrimitive 判断类型是否由 serialize 以二进制方式直接编码。
+// 这类类型即使实现了 encoding.BinaryMarshaler 也保持原有的编码。
+func isPrimitive(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128, reflect.String:
+		return true
+	}
+	return false
+}
+
+// jsonCodec 是基于 encoding/json 的编解码器。
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// gobCodec 是基于 encoding/gob 的编解码器。
+type gobCodec struct {
+	// strict 为 true 时要求一次解码消费全部数据。
+	strict bool
+}
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, errors.Wrap(err, "encode error")
+	}
+	return buf.Bytes(), nil
+}
+
+func (c gobCodec) Unmarshal(data []byte, v any) error {
+	return gobDecode(data, v, c.strict)
+}
+
+// gobDecode 使用 gob 将 data 解码到 v 指向的变量。
+// strict 为 true 时，data 中还有未消费的字节则返回错误。
+func gobDecode(data []byte, v any, strict bool) error {
+	r := bytes.NewReader(data)
+	if err := gob.NewDecoder(r).Decode(v); err != nil {
+		return errors.Wrap(err, "decode error")
+	}
+	if strict && r.Len() > 0 {
+		return errors.Wrapf(errInvalidLength, "%d trailing bytes after gob value", r.Len())
+	}
+	return nil
+}
+
+// msgpackCodec 是基于 MessagePack 的编解码器。
+type msgpackCodec struct{}
+
+func (msgpackCodec) Marshal(v any) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v any) error {
+	return msgpack.Unmarshal(data, v)
+}
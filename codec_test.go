@@ -0,0 +1,146 @@
+package kv
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type codecUser struct {
+	Name string
+	Age  int
+}
+
+func TestCodecs_RoundTrip(t *testing.T) {
+	s, err := Open(Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	want := codecUser{Name: "alice", Age: 30}
+	for name, codec := range codecs {
+		t.Run(name, func(t *testing.T) {
+			view := s.WithCodec(codec)
+			if err := view.Set("codec_user", want); err != nil {
+				t.Fatalf("Set() error = %v", err)
+			}
+			var got codecUser
+			exists, err := view.Get("codec_user", &got)
+			if err != nil || !exists {
+				t.Fatalf("Get() = %v, %v", exists, err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Get() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestJSONCodec_Readable(t *testing.T) {
+	s, err := Open(Options{Codec: JSONCodec})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	if err = s.Set("json_user", codecUser{Name: "bob", Age: 7}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	var raw []byte
+	if _, err = s.WithCodec(rawCodec{}).Get("json_user", &raw); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	var m map[string]any
+	if err = json.Unmarshal(raw, &m); err != nil {
+		t.Fatalf("stored value is not JSON: %v", err)
+	}
+	if m["Name"] != "bob" {
+		t.Errorf("stored JSON = %s", raw)
+	}
+}
+
+func TestHybridCodec_Marshalers(t *testing.T) {
+	s, err := Open(Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	now := time.Now()
+	if err = s.Set("hybrid_time", now); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	var gotTime time.Time
+	if _, err = s.Get("hybrid_time", &gotTime); err != nil || !gotTime.Equal(now) {
+		t.Errorf("Get() time = %v, %v, want %v", gotTime, err, now)
+	}
+
+	// Values written by older versions were gob-encoded and must still decode.
+	if err = s.WithCodec(GobCodec).Set("hybrid_old_time", now); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if _, err = s.Get("hybrid_old_time", &gotTime); err != nil || !gotTime.Equal(now) {
+		t.Errorf("Get() gob time = %v, %v, want %v", gotTime, err, now)
+	}
+
+	if err = s.Set("hybrid_proto", wrapperspb.String("hello")); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	var msg *wrapperspb.StringValue
+	if _, err = s.Get("hybrid_proto", &msg); err != nil || msg.GetValue() != "hello" {
+		t.Errorf("Get() proto = %v, %v, want hello", msg, err)
+	}
+}
+
+// binaryID accepts exactly four bytes in UnmarshalBinary.
+type binaryID struct{ n uint32 }
+
+func (b binaryID) MarshalBinary() ([]byte, error) {
+	return []byte{byte(b.n >> 24), byte(b.n >> 16), byte(b.n >> 8), byte(b.n)}, nil
+}
+
+func (b *binaryID) UnmarshalBinary(data []byte) error {
+	if len(data) != 4 {
+		return fmt.Errorf("binaryID needs 4 bytes, got %d", len(data))
+	}
+	b.n = uint32(data[0])<<24 | uint32(data[1])<<16 | uint32(data[2])<<8 | uint32(data[3])
+	return nil
+}
+
+func TestHybridCodec_UnmarshalBinaryError(t *testing.T) {
+	s, err := Open(Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	if err = s.Set("id", binaryID{n: 42}); err != nil {
+		t.Fatal(err)
+	}
+	var id binaryID
+	if _, err = s.Get("id", &id); err != nil || id.n != 42 {
+		t.Errorf("Get() = %d, %v, want 42", id.n, err)
+	}
+
+	if err = s.Set("id", []byte{1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Get("id", &id); err == nil {
+		t.Error("Get() should return the UnmarshalBinary error")
+	}
+}
+
+// rawCodec returns stored bytes unchanged, for inspecting the encoded form.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v any) ([]byte, error) { return v.([]byte), nil }
+
+func (rawCodec) Unmarshal(data []byte, v any) error {
+	*v.(*[]byte) = append([]byte(nil), data...)
+	return nil
+}
@@ -0,0 +1,163 @@
+package kv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sync"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+/*
+压缩在编码（以及 schema 版本字节）之后、信封之前进行，信封头部始终不压缩。
+压缩后的值以一个字节的算法 ID 开头，并在 UserMeta 中设置 metaCompressed 位，
+因此未压缩的旧值和压缩的新值可以混合存放。压缩后没有变小的值按原样存储。
+*/
+
+// metaCompressed 是 UserMeta 中表示值被压缩的位。
+const metaCompressed byte = 1 << 3
+
+// Compression 是值的压缩算法。
+type Compression byte
+
+const (
+	// NoCompression 不压缩，这是默认值。
+	NoCompression Compression = iota
+	// Zstd 使用 zstd，压缩率高，适合 JSON 和 gob 等文本性较强的数据。
+	Zstd
+	// S2 使用 s2（snappy 的扩展），压缩和解压速度更快。
+	S2
+)
+
+// defaultCompressionThreshold 是 Options.CompressionThreshold 不大于 0 时使用的阈值。
+const defaultCompressionThreshold = 512
+
+// compressions 是配置项 "CACHE COMPRESSION" 可以选择的压缩算法。
+var compressions = map[string]Compression{
+	"none": NoCompression,
+	"zstd": Zstd,
+	"s2":   S2,
+}
+
+var (
+	zstdEncoder = sync.OnceValues(func() (*zstd.Encoder, error) {
+		return zstd.NewWriter(nil)
+	})
+	zstdDecoder = sync.OnceValues(func() (*zstd.Decoder, error) {
+		return zstd.NewReader(nil)
+	})
+)
+
+// checkCompression 校验 Options.Compression 和 Options.CompressionPrefixes 中的压缩算法。
+func checkCompression(opts Options) error {
+	check := func(c Compression) error {
+		switch c {
+		case NoCompression, Zstd, S2:
+			return nil
+		case ZstdDict:
+			return errors.New("ZstdDict is enabled by TrainDictionary and cannot be set in Options")
+		}
+		return errors.Errorf("unknown compression %d", c)
+	}
+	if err := check(opts.Compression); err != nil {
+		return err
+	}
+	for prefix, c := range opts.CompressionPrefixes {
+		if err := check(c); err != nil {
+			return errors.Wrapf(err, "compression of prefix %q", prefix)
+		}
+	}
+	return nil
+}
+
+// compression 返回键 k 使用的压缩算法。
+// Options.CompressionPrefixes 中最长的匹配前缀优先，没有匹配时使用 Options.Compression。
+func (s *Store) compression(k []byte) Compression {
+	c := s.opts.Compression
+	longest := -1
+	for prefix, pc := range s.opts.CompressionPrefixes {
+		if len(prefix) > longest && bytes.HasPrefix(k, []byte(prefix)) {
+			c, longest = pc, len(prefix)
+		}
+	}
+	return c
+}
+
+// compressValue 按键 k 的配置压缩 data。训练过字典的前缀优先使用字典压缩，且不受阈值限制。
+// 返回值:
+// []byte: 压缩后的数据，不需要压缩或压缩后没有变小时返回 data 本身。
+// bool: 是否进行了压缩。
+// error: 压缩过程中发生的任何错误。
+func (s *Store) compressValue(k, data []byte) ([]byte, bool, error) {
+	if z, ok := s.dicts.compress(k, data); ok {
+		if len(z) >= len(data) {
+			return data, false, nil
+		}
+		return z, true, nil
+	}
+	c := s.compression(k)
+	threshold := s.opts.CompressionThreshold
+	if threshold <= 0 {
+		threshold = defaultCompressionThreshold
+	}
+	if c == NoCompression || len(data) < threshold {
+		return data, false, nil
+	}
+	z, err := compress(c, data)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(z) >= len(data) {
+		return data, false, nil
+	}
+	return z, true, nil
+}
+
+// compress 使用算法 c 压缩 data，结果以算法 ID 开头。
+func compress(c Compression, data []byte) ([]byte, error) {
+	dst := []byte{byte(c)}
+	switch c {
+	case Zstd:
+		enc, err := zstdEncoder()
+		if err != nil {
+			return nil, err
+		}
+		return enc.EncodeAll(data, dst), nil
+	case S2:
+		return append(dst, s2.Encode(nil, data)...), nil
+	}
+	return nil, errors.Errorf("unknown compression %d", c)
+}
+
+// decompress 解压 compress 或 compressDict 生成的数据。
+func (s *Store) decompress(data []byte) ([]byte, error) {
+	if len(data) > 0 && Compression(data[0]) == ZstdDict {
+		if len(data) < 5 {
+			return nil, errors.New("missing zstd dictionary id")
+		}
+		return s.dicts.decode(binary.BigEndian.Uint32(data[1:5]), data[5:])
+	}
+	return decompress(data)
+}
+
+// decompress 解压 compress 生成的数据。
+func decompress(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, errors.New("missing compression flag")
+	}
+	switch Compression(data[0]) {
+	case Zstd:
+		dec, err := zstdDecoder()
+		if err != nil {
+			return nil, err
+		}
+		out, err := dec.DecodeAll(data[1:], nil)
+		return out, errors.Wrap(err, "zstd")
+	case S2:
+		out, err := s2.Decode(nil, data[1:])
+		return out, errors.Wrap(err, "s2")
+	}
+	return nil, errors.Errorf("unknown compression %d", data[0])
+}
@@ -0,0 +1,99 @@
+package kv
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// storedSize returns the stored byte size and UserMeta of key.
+func storedSize(t *testing.T, s *Store, key string) (int, byte) {
+	t.Helper()
+	k, _ := serializeKey(key)
+	var size int
+	var meta byte
+	if err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(k)
+		if err != nil {
+			return err
+		}
+		size, meta = int(item.ValueSize()), item.UserMeta()
+		return nil
+	}); err != nil {
+		t.Fatalf("read %q: %v", key, err)
+	}
+	return size, meta
+}
+
+func TestCompression_RoundTrip(t *testing.T) {
+	big := strings.Repeat(`{"name":"kv","tags":["cache","badger"]},`, 200)
+	for _, c := range []Compression{Zstd, S2} {
+		s, err := Open(Options{Compression: c, Envelope: true})
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+		if err = s.Set("big", big); err != nil {
+			t.Fatal(err)
+		}
+		if err = s.Set("small", "tiny"); err != nil {
+			t.Fatal(err)
+		}
+
+		size, meta := storedSize(t, s, "big")
+		if meta&metaCompressed == 0 || size >= len(big)/5 {
+			t.Errorf("compression %d: stored %d bytes (meta %b) for %d input bytes", c, size, meta, len(big))
+		}
+		if _, meta = storedSize(t, s, "small"); meta&metaCompressed != 0 {
+			t.Errorf("compression %d: values below the threshold should be stored raw", c)
+		}
+
+		var got string
+		if _, err = s.Get("big", &got); err != nil || got != big {
+			t.Errorf("compression %d: Get() mismatch, err = %v", c, err)
+		}
+		if ok, err := s.CompareAndSwap("big", big, "replaced"); err != nil || !ok {
+			t.Errorf("compression %d: CompareAndSwap() = %v, %v, want true", c, ok, err)
+		}
+		_ = s.Close()
+	}
+}
+
+func TestCompression_Prefixes(t *testing.T) {
+	s, err := Open(Options{
+		CompressionPrefixes:  map[string]Compression{"logs:": S2, "logs:raw:": NoCompression},
+		CompressionThreshold: 16,
+	})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	value := strings.Repeat("line ", 100)
+	for _, key := range []string{"logs:1", "logs:raw:1", "other"} {
+		if err = s.Set(key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for key, want := range map[string]bool{"logs:1": true, "logs:raw:1": false, "other": false} {
+		if _, meta := storedSize(t, s, key); (meta&metaCompressed != 0) != want {
+			t.Errorf("%s compressed = %v, want %v", key, !want, want)
+		}
+	}
+}
+
+func TestCompression_ReadsUncompressed(t *testing.T) {
+	s, err := Open(Options{Compression: Zstd, CompressionThreshold: 1})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	// A value written before compression was enabled has no flag.
+	data, _ := serialize(strings.Repeat("a", 1000))
+	putRaw(t, s, "old", data)
+	var got string
+	if _, err = s.Get("old", &got); err != nil || len(got) != 1000 {
+		t.Errorf("Get() = %d bytes, %v, want 1000", len(got), err)
+	}
+}
@@ -0,0 +1,175 @@
+package kv
+
+import (
+	"bytes"
+	"reflect"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/pkg/errors"
+)
+
+// SetNX 仅在键不存在时将键值对存入默认 Store，可选择性地设置生存时间 (TTL)。
+// 适用于幂等键和抢占式的领导者声明。
+// K, V 是泛型参数，代表任意类型的键和值。
+// key: 键。
+// value: 值。
+// ttl: 可选参数，生命周期，单位毫秒。
+// 返回值:
+// bool: 是否写入了值。
+// error: 操作中发生的任何错误。
+func SetNX[K, V any](key K, value V, ttl ...int64) (bool, error) {
+	s, err := defaultStore()
+	if err != nil {
+		return false, err
+	}
+	return s.SetNX(key, value, ttl...)
+}
+
+// SetXX 仅在键已存在时将键值对存入默认 Store，可选择性地设置生存时间 (TTL)。
+// 参数与返回值同 SetNX。
+func SetXX[K, V any](key K, value V, ttl ...int64) (bool, error) {
+	s, err := defaultStore()
+	if err != nil {
+		return false, err
+	}
+	return s.SetXX(key, value, ttl...)
+}
+
+// CompareAndSwap 仅在默认 Store 中键的当前值与 old 相同时，将其替换为 new。
+// 比较的是序列化之后的字节。键不存在时不写入。
+// K, V 是泛型参数，代表任意类型的键和值。
+// key: 键。
+// old: 期望的当前值。
+// new: 新值。
+// ttl: 可选参数，生命周期，单位毫秒。
+// 返回值:
+// bool: 是否发生了替换。
+// error: 操作中发生的任何错误。
+func CompareAndSwap[K, V any](key K, old, new V, ttl ...int64) (bool, error) {
+	s, err := defaultStore()
+	if err != nil {
+		return false, err
+	}
+	return s.CompareAndSwap(key, old, new, ttl...)
+}
+
+// GetSet 将键值对存入默认 Store，并返回之前的值。
+// K, V 是泛型参数，代表任意类型的键和值。
+// key: 键。
+// value: 新值。
+// ttl: 可选参数，生命周期，单位毫秒。
+// 返回值:
+// V: 之前的值，键不存在时为零值。
+// bool: 之前键是否存在。
+// error: 操作中发生的任何错误。
+func GetSet[K, V any](key K, value V, ttl ...int64) (V, bool, error) {
+	var old V
+	s, err := defaultStore()
+	if err != nil {
+		return old, false, err
+	}
+	exists, err := s.GetSet(key, value, &old, ttl...)
+	return old, exists, err
+}
+
+// SetNX 仅在键不存在时写入，语义与包级别的 SetNX 相同。
+func (s *Store) SetNX(key, value any, ttl ...int64) (bool, error) {
+	return s.setIf(key, value, ttl, func(item *badger.Item) (bool, error) {
+		return item == nil, nil
+	})
+}
+
+// SetXX 仅在键已存在时写入，语义与包级别的 SetXX 相同。
+func (s *Store) SetXX(key, value any, ttl ...int64) (bool, error) {
+	return s.setIf(key, value, ttl, func(item *badger.Item) (bool, error) {
+		return item != nil, nil
+	})
+}
+
+// CompareAndSwap 仅在当前值与 old 相同时替换为 new，语义与包级别的 CompareAndSwap 相同。
+func (s *Store) CompareAndSwap(key, old, new any, ttl ...int64) (bool, error) {
+	k, err := serializeKey(key)
+	if err != nil {
+		return false, err
+	}
+	o, meta, err := s.marshal(k, old)
+	if err != nil {
+		return false, err
+	}
+	// 比较去掉信封并解压之后的编码，使比较结果不受信封和压缩配置的影响。
+	if o, err = s.rawValue(k, o, meta); err != nil {
+		return false, err
+	}
+	return s.setIf(key, new, ttl, func(item *badger.Item) (bool, error) {
+		if item == nil {
+			return false, nil
+		}
+		var equal bool
+		err := item.Value(func(val []byte) error {
+			raw, err := s.rawValue(item.Key(), val, item.UserMeta())
+			equal = err == nil && bytes.Equal(raw, o)
+			return nil
+		})
+		return equal, err
+	})
+}
+
+// GetSet 写入新值，并将之前的值解码到 old 指向的变量，语义与包级别的 GetSet 相同。
+// old: 接收之前的值的指针，不能为 nil。
+func (s *Store) GetSet(key, value, old any, ttl ...int64) (bool, error) {
+	out := reflect.ValueOf(old)
+	if out.Kind() != reflect.Ptr || out.IsNil() {
+		return false, errors.New("old must be a non-nil pointer")
+	}
+	out = out.Elem()
+
+	var exists bool
+	_, err := s.setIf(key, value, ttl, func(item *badger.Item) (bool, error) {
+		// 事务可能因冲突而重试，每次都从零值开始。
+		out.SetZero()
+		exists = item != nil
+		if item == nil {
+			return true, nil
+		}
+		return true, item.Value(func(val []byte) error {
+			return s.unmarshal(item, val, old)
+		})
+	})
+	return exists, err
+}
+
+// setIf 在一个读写事务中读取键的当前条目，cond 返回 true 时写入新值。
+// 键不存在时 cond 收到的 item 为 nil。事务冲突时按 Options.ConflictRetries 重试。
+// 返回值表示是否写入了新值。
+func (s *Store) setIf(key, value any, ttl []int64, cond func(item *badger.Item) (bool, error)) (bool, error) {
+	k, err := serializeKey(key)
+	if err != nil {
+		return false, err
+	}
+	v, meta, err := s.marshal(k, value)
+	if err != nil {
+		return false, err
+	}
+
+	var written bool
+	err = s.update(func(txn *badger.Txn) error {
+		written = false
+		item, err := txn.Get(k)
+		if err != nil {
+			if !errors.Is(err, badger.ErrKeyNotFound) {
+				return err
+			}
+			item = nil
+		}
+		ok, err := cond(item)
+		if err != nil || !ok {
+			return err
+		}
+		if err = txn.SetEntry(newEntry(k, v, meta, ttl)); err != nil {
+			return err
+		}
+		written = true
+		return nil
+	})
+	return written, err
+}
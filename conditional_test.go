@@ -0,0 +1,86 @@
+package kv
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSetNX_SetXX(t *testing.T) {
+	s, err := Open(Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	ok, err := s.SetXX("cond_key", "a")
+	if err != nil || ok {
+		t.Fatalf("SetXX() on missing key = %v, %v, want false", ok, err)
+	}
+	if ok, err = s.SetNX("cond_key", "a"); err != nil || !ok {
+		t.Fatalf("SetNX() on missing key = %v, %v, want true", ok, err)
+	}
+	if ok, err = s.SetNX("cond_key", "b"); err != nil || ok {
+		t.Fatalf("SetNX() on existing key = %v, %v, want false", ok, err)
+	}
+	if ok, err = s.SetXX("cond_key", "c"); err != nil || !ok {
+		t.Fatalf("SetXX() on existing key = %v, %v, want true", ok, err)
+	}
+	var got string
+	if _, err = s.Get("cond_key", &got); err != nil || got != "c" {
+		t.Errorf("Get() = %v, %v, want c", got, err)
+	}
+}
+
+func TestSetNX_Concurrent(t *testing.T) {
+	s, err := Open(Options{ConflictRetries: 100})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	var winners int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ok, err := s.SetNX("leader", i)
+			if err != nil {
+				t.Errorf("SetNX() error = %v", err)
+				return
+			}
+			if ok {
+				atomic.AddInt32(&winners, 1)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if winners != 1 {
+		t.Errorf("SetNX() winners = %d, want 1", winners)
+	}
+}
+
+func TestCompareAndSwap_GetSet(t *testing.T) {
+	s, err := Open(Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	var old int64
+	exists, err := s.GetSet("cas_key", int64(1), &old)
+	if err != nil || exists {
+		t.Fatalf("GetSet() on missing key = %v, %v, want false", exists, err)
+	}
+	ok, err := s.CompareAndSwap("cas_key", int64(2), int64(3))
+	if err != nil || ok {
+		t.Fatalf("CompareAndSwap() with wrong old = %v, %v, want false", ok, err)
+	}
+	if ok, err = s.CompareAndSwap("cas_key", int64(1), int64(3)); err != nil || !ok {
+		t.Fatalf("CompareAndSwap() with right old = %v, %v, want true", ok, err)
+	}
+	if exists, err = s.GetSet("cas_key", int64(4), &old); err != nil || !exists || old != 3 {
+		t.Errorf("GetSet() = %v, %v, %v, want 3, true", old, exists, err)
+	}
+}
@@ -1,9 +1,180 @@
 package kv
 
-import conf "github.com/clong1995/go-config"
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
 
-var cachePath string
+	pcolor "github.com/clong1995/go-ansi-color"
+	conf "github.com/clong1995/go-config"
+	"github.com/pkg/errors"
+)
+
+var (
+	cachePath string
+	// startMode 决定默认 Store 何时打开，取值见 startEager、startLazy 和 startManual。
+	startMode string
+	// codecName 是默认 Store 使用的编解码器名称，取值为 codecs 的键。
+	codecName string
+	// compressionName 是默认 Store 的压缩算法名称，取值为 compressions 的键。
+	compressionName string
+	// encryptionKeyFile 是默认 Store 的加密密钥文件路径。
+	encryptionKeyFile string
+	// encryptionKeyEnv 是保存默认 Store 加密密钥的环境变量名。
+	encryptionKeyEnv string
+	// masterKeyFile 是默认 Store 密封前缀使用的主密钥文件路径。
+	masterKeyFile string
+	// masterKeyEnv 是保存默认 Store 主密钥的环境变量名。
+	masterKeyEnv string
+	// sealedPrefixes 是默认 Store 需要密封的键前缀。
+	sealedPrefixes []string
+	// settings 是 settingKeys 中已设置的配置项的原始值，在 configOptions 中解析和校验。
+	settings map[string]string
+	// tuning 是 tuningKeys 中已设置的配置项的原始值，在 configOptions 中解析和校验。
+	tuning map[string]string
+)
+
+// settingKeys 是映射到 Options 中 kv 自身选项的配置项，取值说明见 applySettings。
+var settingKeys = []string{
+	"CACHE CONFLICT RETRIES",
+	"CACHE ENVELOPE",
+	"CACHE STRICT DECODE",
+	"CACHE MIGRATE ON READ",
+	"CACHE STREAM CHUNK SIZE",
+	"CACHE STREAM COLLECT INTERVAL",
+	"CACHE COMPRESSION THRESHOLD",
+}
+
+const (
+	// startEager 在包被导入时立即打开默认 Store，这是默认行为。
+	startEager = "eager"
+	// startLazy 在第一次使用包级别函数时才打开默认 Store。
+	startLazy = "lazy"
+	// startManual 只在调用 Start 后才打开默认 Store。
+	startManual = "manual"
+)
 
 func config() {
 	cachePath, _ = conf.Value[string]("CACHE PATH")
+	startMode, _ = conf.Value[string]("CACHE START")
+	if err := checkStartMode(startMode); err != nil {
+		pcolor.PrintFatal(prefix, "%v", err)
+	}
+	codecName, _ = conf.Value[string]("CACHE CODEC")
+	compressionName, _ = conf.Value[string]("CACHE COMPRESSION")
+	encryptionKeyFile, _ = conf.Value[string]("CACHE ENCRYPTION KEY FILE")
+	encryptionKeyEnv, _ = conf.Value[string]("CACHE ENCRYPTION KEY ENV")
+	masterKeyFile, _ = conf.Value[string]("CACHE MASTER KEY FILE")
+	masterKeyEnv, _ = conf.Value[string]("CACHE MASTER KEY ENV")
+	sealedPrefixes, _ = conf.Value[[]string]("CACHE SEALED PREFIXES")
+	settings = configValues(settingKeys)
+	tuning = configValues(tuningKeys)
+}
+
+// configValues 以字符串读取 keys 中已设置的配置项，返回配置项名称到原始值的映射。
+// 取值在 configOptions 中解析，格式错误时打开默认 Store 失败，而不是静默地使用默认值。
+func configValues(keys []string) map[string]string {
+	values := map[string]string{}
+	for _, key := range keys {
+		if v, ok := conf.Value[string](key); ok {
+			values[key] = v
+		}
+	}
+	return values
+}
+
+// checkStartMode 检查 "CACHE START" 配置项的取值，空字符串等同于 startEager。
+func checkStartMode(mode string) error {
+	switch mode {
+	case "", startEager, startLazy, startManual:
+		return nil
+	}
+	return errors.Errorf("unknown CACHE START %q, must be %s, %s or %s", mode, startEager, startLazy, startManual)
+}
+
+// configOptions 根据配置项生成打开默认 Store 的选项。
+func configOptions(path string) (Options, error) {
+	opts := Options{
+		Path:           path,
+		SealedPrefixes: sealedPrefixes,
+	}
+	if err := applySettings(&opts, settings); err != nil {
+		return opts, err
+	}
+	if codecName != "" {
+		codec, ok := codecs[codecName]
+		if !ok {
+			return opts, errors.Errorf("unknown CACHE CODEC %q", codecName)
+		}
+		opts.Codec = codec
+	}
+	if compressionName != "" {
+		c, ok := compressions[compressionName]
+		if !ok {
+			return opts, errors.Errorf("unknown CACHE COMPRESSION %q", compressionName)
+		}
+		opts.Compression = c
+	}
+	key, err := loadKey("CACHE ENCRYPTION KEY", encryptionKeyFile, encryptionKeyEnv)
+	if err != nil {
+		return opts, err
+	}
+	opts.EncryptionKey = key
+	if opts.MasterKey, err = loadKey("CACHE MASTER KEY", masterKeyFile, masterKeyEnv); err != nil {
+		return opts, err
+	}
+	if err = applyTuning(&opts, tuning); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// applySettings 解析 kv 选项配置项的原始值 values 并设置到 opts 上，values 的键是配置项名称，空值表示使用默认值。
+//   - "CACHE CONFLICT RETRIES"：int，见 Options.ConflictRetries。
+//   - "CACHE ENVELOPE"、"CACHE STRICT DECODE"、"CACHE MIGRATE ON READ"：bool。
+//   - "CACHE STREAM CHUNK SIZE"：大小，格式与 parseSize 相同，不能超过 4GB-1。
+//   - "CACHE STREAM COLLECT INTERVAL"：正的时长，例如 "10m"，或者 "off" 表示不在后台回收。
+//   - "CACHE COMPRESSION THRESHOLD"：大小，格式与 parseSize 相同。
+func applySettings(opts *Options, values map[string]string) error {
+	for key, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		var err error
+		switch key {
+		case "CACHE CONFLICT RETRIES":
+			opts.ConflictRetries, err = strconv.Atoi(value)
+		case "CACHE ENVELOPE":
+			opts.Envelope, err = strconv.ParseBool(value)
+		case "CACHE STRICT DECODE":
+			opts.StrictDecode, err = strconv.ParseBool(value)
+		case "CACHE MIGRATE ON READ":
+			opts.MigrateOnRead, err = strconv.ParseBool(value)
+		case "CACHE STREAM CHUNK SIZE":
+			var n int64
+			if n, err = parseSize(value); err == nil && n > math.MaxUint32 {
+				err = errors.New("chunk size must be less than 4GB")
+			}
+			opts.StreamChunkSize = int(n)
+		case "CACHE STREAM COLLECT INTERVAL":
+			if value == "off" {
+				opts.StreamCollectInterval = -1
+				break
+			}
+			opts.StreamCollectInterval, err = time.ParseDuration(value)
+			if err == nil && opts.StreamCollectInterval <= 0 {
+				err = errors.New("must be a positive duration or off")
+			}
+		case "CACHE COMPRESSION THRESHOLD":
+			var n int64
+			n, err = parseSize(value)
+			opts.CompressionThreshold = int(n)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s %q", key, value)
+		}
+	}
+	return nil
 }
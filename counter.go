@@ -0,0 +1,207 @@
+package kv
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/pkg/errors"
+)
+
+// Incr 将默认 Store 中键对应的 int64 原子地增加 delta，并返回新值。
+// 键不存在时视为 0。数值的存储格式与 serialize 对 int64 的编码相同，因此 Get[K, int64] 可以直接读取。
+// K 是泛型参数，代表任意类型的键。
+// key: 键。
+// delta: 增量，可以为负数。
+// ttl: 可选参数，生命周期，单位毫秒。如果不提供，则保留键原有的过期时间。
+// 返回值:
+// int64: 增加后的值。
+// error: 操作中发生的任何错误，已有的值不是 8 字节时返回错误。
+func Incr[K any](key K, delta int64, ttl ...int64) (int64, error) {
+	s, err := defaultStore()
+	if err != nil {
+		return 0, err
+	}
+	return s.Incr(key, delta, ttl...)
+}
+
+// Decr 将默认 Store 中键对应的 int64 原子地减少 delta，并返回新值。
+func Decr[K any](key K, delta int64, ttl ...int64) (int64, error) {
+	return Incr(key, -delta, ttl...)
+}
+
+// IncrFloat 将默认 Store 中键对应的 float64 原子地增加 delta，并返回新值。
+// 数值的存储格式与 serialize 对 float64 的编码相同，因此 Get[K, float64] 可以直接读取。
+func IncrFloat[K any](key K, delta float64, ttl ...int64) (float64, error) {
+	s, err := defaultStore()
+	if err != nil {
+		return 0, err
+	}
+	return s.IncrFloat(key, delta, ttl...)
+}
+
+// Incr 将键对应的 int64 原子地增加 delta，并返回新值，语义与包级别的 Incr 相同。
+func (s *Store) Incr(key any, delta int64, ttl ...int64) (int64, error) {
+	var n int64
+	err := s.incr(key, ttl, func(old []byte) ([]byte, error) {
+		// fn 在事务冲突重试时会被再次调用，每次都从旧值重新计算。
+		n = 0
+		if old != nil {
+			if len(old) != 8 {
+				return nil, errors.New("value is not an int64")
+			}
+			n = int64(binary.BigEndian.Uint64(old))
+		}
+		n += delta
+		buf := make([]byte, 8)
+		binary.BigEndian.PutUint64(buf, uint64(n))
+		return buf, nil
+	})
+	return n, err
+}
+
+// Decr 将键对应的 int64 原子地减少 delta，并返回新值。
+func (s *Store) Decr(key any, delta int64, ttl ...int64) (int64, error) {
+	return s.Incr(key, -delta, ttl...)
+}
+
+// IncrFloat 将键对应的 float64 原子地增加 delta，并返回新值，语义与包级别的 IncrFloat 相同。
+func (s *Store) IncrFloat(key any, delta float64, ttl ...int64) (float64, error) {
+	var f float64
+	err := s.incr(key, ttl, func(old []byte) ([]byte, error) {
+		f = 0
+		if old != nil {
+			if len(old) != 8 {
+				return nil, errors.New("value is not a float64")
+			}
+			f = float64FromBits(binary.BigEndian.Uint64(old))
+		}
+		f += delta
+		buf := make([]byte, 8)
+		binary.BigEndian.PutUint64(buf, float64Bits(f))
+		return buf, nil
+	})
+	return f, err
+}
+
+// incr 在读写事务中读取键的原始值，用 fn 计算新值后写回。
+// 事务冲突时按 Options.ConflictRetries 重试，fn 会被重新调用。
+func (s *Store) incr(key any, ttl []int64, fn func(old []byte) ([]byte, error)) error {
+	k, err := serializeKey(key)
+	if err != nil {
+		return err
+	}
+	return s.update(func(txn *badger.Txn) error {
+		var old []byte
+		var expiresAt uint64
+		item, err := txn.Get(k)
+		switch {
+		case err == nil:
+			if old, err = item.ValueCopy(nil); err != nil {
+				return err
+			}
+			// 通过 Set 写入的数值可能带有信封，计数器只处理原始编码。
+			if old, err = s.rawValue(k, old, item.UserMeta()); err != nil {
+				return err
+			}
+			expiresAt = item.ExpiresAt()
+		case !errors.Is(err, badger.ErrKeyNotFound):
+			return err
+		}
+
+		v, err := fn(old)
+		if err != nil {
+			return err
+		}
+		var meta byte
+		v, sealed, err := s.seal(k, k, v)
+		if err != nil {
+			return err
+		}
+		if sealed {
+			meta = metaSealed
+		}
+		entry := newEntry(k, v, meta, ttl)
+		if len(ttl) == 0 {
+			entry.ExpiresAt = expiresAt
+		}
+		return txn.SetEntry(entry)
+	})
+}
+
+// Counter 是基于 Badger 合并操作符 (MergeOperator) 的高吞吐计数器。
+// Add 只追加增量而不读取旧值，因此不会产生事务冲突，适合写入非常频繁的计数器。
+// 增量在后台每隔一段时间合并一次，读取当前值必须使用 Value，而不是 Get。
+// 合并操作符不支持 TTL，也不能用于密封前缀下的键。使用完毕后必须调用 Stop。
+type Counter struct {
+	op *badger.MergeOperator
+}
+
+// defaultCounterInterval 是 Counter 在后台合并增量的时间间隔。
+const defaultCounterInterval = time.Second
+
+// NewCounter 为默认 Store 中的键创建一个高吞吐计数器。
+// K 是泛型参数，代表任意类型的键。
+// key: 键，其已有的值必须是 int64 或不存在。
+func NewCounter[K any](key K) (*Counter, error) {
+	s, err := defaultStore()
+	if err != nil {
+		return nil, err
+	}
+	return s.Counter(key)
+}
+
+// Counter 为 Store 中的键创建一个高吞吐计数器。
+func (s *Store) Counter(key any) (*Counter, error) {
+	k, err := serializeKey(key)
+	if err != nil {
+		return nil, err
+	}
+	// 合并操作符直接读写原始的值，无法加密。
+	if _, ok := s.sealedPrefix(k); ok {
+		return nil, errors.Errorf("cannot create a Counter under sealed prefix for key %q", k)
+	}
+	return &Counter{op: s.db.GetMergeOperator(k, addInt64, defaultCounterInterval)}, nil
+}
+
+// Add 追加一个增量。
+func (c *Counter) Add(delta int64) error {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, uint64(delta))
+	return c.op.Add(buf)
+}
+
+// Value 合并所有增量并返回计数器的当前值。
+func (c *Counter) Value() (int64, error) {
+	v, err := c.op.Get()
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	if len(v) != 8 {
+		return 0, errors.New("value is not an int64")
+	}
+	return int64(binary.BigEndian.Uint64(v)), nil
+}
+
+// Stop 停止后台合并，并写入最终的合并结果。
+func (c *Counter) Stop() {
+	c.op.Stop()
+}
+
+// addInt64 是 Counter 的合并函数，将两个 8 字节的大端 int64 相加。
+// 长度不正确的值被视为 0。
+func addInt64(existing, delta []byte) []byte {
+	var a, b int64
+	if len(existing) == 8 {
+		a = int64(binary.BigEndian.Uint64(existing))
+	}
+	if len(delta) == 8 {
+		b = int64(binary.BigEndian.Uint64(delta))
+	}
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, uint64(a+b))
+	return buf
+}
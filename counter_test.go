@@ -0,0 +1,94 @@
+package kv
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestIncr_Concurrent(t *testing.T) {
+	s, err := Open(Options{ConflictRetries: 1000})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.Incr("counter", 2); err != nil {
+				t.Errorf("Incr() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	n, err := s.Decr("counter", 1)
+	if err != nil {
+		t.Fatalf("Decr() error = %v", err)
+	}
+	if n != 99 {
+		t.Errorf("Decr() = %d, want 99", n)
+	}
+
+	var got int64
+	if _, err = s.Get("counter", &got); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got != 99 {
+		t.Errorf("Get() = %d, want 99", got)
+	}
+}
+
+func TestIncrFloat(t *testing.T) {
+	s, err := Open(Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	if err = s.Set("float_counter", 1.5); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	f, err := s.IncrFloat("float_counter", 0.25)
+	if err != nil {
+		t.Fatalf("IncrFloat() error = %v", err)
+	}
+	if f != 1.75 {
+		t.Errorf("IncrFloat() = %v, want 1.75", f)
+	}
+
+	if err = s.Set("not_a_counter", "abc"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if _, err = s.Incr("not_a_counter", 1); err == nil {
+		t.Errorf("Incr() on a string value should return error")
+	}
+}
+
+func TestCounter(t *testing.T) {
+	s, err := Open(Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	c, err := s.Counter("hot_counter")
+	if err != nil {
+		t.Fatalf("Counter() error = %v", err)
+	}
+	defer c.Stop()
+	for i := 0; i < 100; i++ {
+		if err = c.Add(1); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+	n, err := c.Value()
+	if err != nil {
+		t.Fatalf("Value() error = %v", err)
+	}
+	if n != 100 {
+		t.Errorf("Value() = %d, want 100", n)
+	}
+}
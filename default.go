@@ -0,0 +1,114 @@
+package kv
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	pcolor "github.com/clong1995/go-ansi-color"
+	"github.com/pkg/errors"
+)
+
+var (
+	// std 是包级别函数使用的默认 Store。
+	std atomic.Pointer[Store]
+	// stdMutex 保证默认 Store 只被打开一次。
+	stdMutex sync.Mutex
+)
+
+// ErrNotStarted 表示默认 Store 尚未打开。
+// 当 "CACHE START" 配置为 "manual" 且尚未调用 Start 时，包级别函数返回此错误。
+var ErrNotStarted = errors.New("kv not started")
+
+// start 函数在包被导入时自动执行。
+// 它负责打开默认的 Store，失败时直接退出程序。
+// 数据库路径可以通过 "CACHE PATH" 配置项来设置。
+func start() {
+	if _, err := openDefault(cachePath); err != nil {
+		pcolor.PrintFatal(prefix, "%v", err)
+	}
+}
+
+// Start 使用 path 打开默认的 Store。
+// 它用于 "CACHE START" 配置为 "manual" 的场景，以便在运行时决定数据库路径。
+// path 的含义与 "CACHE PATH" 配置项相同。如果默认 Store 已经打开，则返回错误。
+func Start(path string) error {
+	stdMutex.Lock()
+	defer stdMutex.Unlock()
+	if std.Load() != nil {
+		return errors.New("kv already started")
+	}
+	_, err := openLocked(path)
+	return err
+}
+
+// defaultStore 返回默认的 Store。
+// 如果 "CACHE START" 配置为 "lazy"，则在第一次调用时打开它。
+func defaultStore() (*Store, error) {
+	if s := std.Load(); s != nil {
+		return s, nil
+	}
+	if startMode != startLazy {
+		return nil, ErrNotStarted
+	}
+	return openDefault(cachePath)
+}
+
+// openDefault 打开默认的 Store，如果已经打开则直接返回。
+func openDefault(path string) (*Store, error) {
+	stdMutex.Lock()
+	defer stdMutex.Unlock()
+	if s := std.Load(); s != nil {
+		return s, nil
+	}
+	return openLocked(path)
+}
+
+// openLocked 打开默认的 Store，调用者必须持有 stdMutex。
+// 如果路径是 "./"，它在当前执行文件的目录下创建一个 ".kv" 文件夹作为数据库路径。
+// 如果路径为空字符串，则使用内存模式。
+func openLocked(p string) (*Store, error) {
+	// 如果路径是 "./"，则解析为当前可执行文件的目录。
+	if p == "./" {
+		exePath, err := os.Executable()
+		if err != nil {
+			return nil, err
+		}
+		p = path.Join(filepath.Dir(exePath), ".kv")
+	}
+	opts, err := configOptions(p)
+	if err != nil {
+		return nil, err
+	}
+	s, err := Open(opts)
+	if err != nil {
+		return nil, err
+	}
+	std.Store(s)
+	// 打印连接成功的消息。
+	if p == "" {
+		pcolor.PrintSucc(prefix, "conn in memory")
+	} else {
+		pcolor.PrintSucc(prefix, "conn %v", p)
+	}
+	return s, nil
+}
+
+// Close 函数用于关闭默认 Store 的数据库连接。
+// 在程序退出前调用此函数是很好的做法，以确保所有数据都被正确写入磁盘。
+// 如果默认 Store 尚未打开，则不做任何操作。
+func Close() {
+	stdMutex.Lock()
+	defer stdMutex.Unlock()
+	s := std.Swap(nil)
+	if s == nil {
+		return
+	}
+	if err := s.Close(); err != nil {
+		pcolor.PrintError(prefix, err)
+		return
+	}
+	pcolor.PrintSucc(prefix, "conn closed")
+}
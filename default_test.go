@@ -0,0 +1,58 @@
+package kv
+
+import (
+	"testing"
+)
+
+func TestStart_AlreadyStarted(t *testing.T) {
+	if _, err := defaultStore(); err != nil {
+		t.Fatalf("defaultStore() error = %v", err)
+	}
+	if err := Start(""); err == nil {
+		t.Errorf("Start() should fail when the default store is already open")
+	}
+}
+
+func TestCheckStartMode(t *testing.T) {
+	for _, mode := range []string{"", "eager", "lazy", "manual"} {
+		if err := checkStartMode(mode); err != nil {
+			t.Errorf("checkStartMode(%q) error = %v", mode, err)
+		}
+	}
+	if err := checkStartMode("eagre"); err == nil {
+		t.Error("checkStartMode(\"eagre\") should fail")
+	}
+}
+
+func TestApplySettings(t *testing.T) {
+	var opts Options
+	if err := applySettings(&opts, map[string]string{
+		"CACHE CONFLICT RETRIES":        "-1",
+		"CACHE ENVELOPE":                "true",
+		"CACHE STRICT DECODE":           " 1 ",
+		"CACHE STREAM CHUNK SIZE":       "64KB",
+		"CACHE STREAM COLLECT INTERVAL": "off",
+		"CACHE COMPRESSION THRESHOLD":   "",
+	}); err != nil {
+		t.Fatalf("applySettings() error = %v", err)
+	}
+	if opts.ConflictRetries != -1 || !opts.Envelope || !opts.StrictDecode || opts.StreamChunkSize != 64<<10 ||
+		opts.StreamCollectInterval != -1 || opts.CompressionThreshold != 0 {
+		t.Errorf("applySettings() = %+v", opts)
+	}
+
+	// Malformed values must fail instead of silently falling back to the defaults.
+	for key, value := range map[string]string{
+		"CACHE CONFLICT RETRIES":        "three",
+		"CACHE ENVELOPE":                "yes",
+		"CACHE STRICT DECODE":           "on",
+		"CACHE MIGRATE ON READ":         "maybe",
+		"CACHE STREAM CHUNK SIZE":       "8GB",
+		"CACHE STREAM COLLECT INTERVAL": "-5m",
+		"CACHE COMPRESSION THRESHOLD":   "-1",
+	} {
+		if err := applySettings(&Options{}, map[string]string{key: value}); err == nil {
+			t.Errorf("applySettings(%s = %q) should fail", key, value)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package kv
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"sync"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/klauspost/compress/dict"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+/*
+字典压缩用于同一前缀下的大量小值，例如用户资料。TrainDictionary 从前缀下的现有值中采样训练 zstd 字典，
+之后写入该前缀下的值时自动使用字典压缩，不受 Options.CompressionThreshold 的限制。
+
+字典保存在数据库的系统键中：
+  - systemPrefix + "dict/" + ID：字典，内容为 uvarint 前缀长度 + 前缀 + zstd 字典。
+  - systemPrefix + "dictcur/" + 前缀：该前缀当前使用的字典 ID。
+每个字典有唯一的 ID，并记录在压缩后的值中。重新训练只会改变前缀当前使用的字典，
+旧的字典仍然保留，因此用旧字典压缩的值始终可以读取。
+*/
+
+// ZstdDict 是使用训练得到的字典的 zstd 压缩。它只由 TrainDictionary 启用，在 Options 中指定时 Open 返回错误。
+const ZstdDict Compression = 3
+
+const (
+	// defaultDictSamples 是 TrainDictionary 的 maxSamples 不大于 0 时使用的采样数。
+	defaultDictSamples = 1000
+	// maxDictSize 是训练得到的字典的最大字节数。
+	maxDictSize = 64 << 10
+)
+
+// dictionaries 是一个 Store 已加载的字典。
+type dictionaries struct {
+	mu sync.RWMutex
+	// encoders 是每个前缀当前使用的字典的编码器，ids 是对应的字典 ID。
+	encoders map[string]*zstd.Encoder
+	ids      map[string]uint32
+	// all 是所有字典，decoder 是注册了所有字典的解码器，没有字典时为 nil。
+	all     map[uint32][]byte
+	decoder *zstd.Decoder
+}
+
+func newDictionaries() *dictionaries {
+	return &dictionaries{
+		encoders: map[string]*zstd.Encoder{},
+		ids:      map[string]uint32{},
+		all:      map[uint32][]byte{},
+	}
+}
+
+// dictEntry 是一个待加载的字典。
+type dictEntry struct {
+	prefix string
+	id     uint32
+	raw    []byte
+	// current 表示它是否为 prefix 当前使用的字典。
+	current bool
+}
+
+// reset 卸载所有字典，并关闭编码器和解码器。
+func (d *dictionaries) reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, enc := range d.encoders {
+		_ = enc.Close()
+	}
+	clear(d.encoders)
+	clear(d.ids)
+	clear(d.all)
+	if d.decoder != nil {
+		d.decoder.Close()
+		d.decoder = nil
+	}
+}
+
+// add 加载字典 entries。解码器只在注册字典时创建，因此加载数据库中的所有字典时应一次传入，
+// 被替换下来的解码器和编码器会被关闭。
+func (d *dictionaries) add(entries ...dictEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, e := range entries {
+		d.all[e.id] = e.raw
+	}
+	dicts := make([][]byte, 0, len(d.all))
+	for _, b := range d.all {
+		dicts = append(dicts, b)
+	}
+	decoder, err := zstd.NewReader(nil, zstd.WithDecoderDicts(dicts...))
+	if err != nil {
+		return err
+	}
+	if d.decoder != nil {
+		d.decoder.Close()
+	}
+	d.decoder = decoder
+	for _, e := range entries {
+		if !e.current {
+			continue
+		}
+		if err = d.setCurrent(e.prefix, e.id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setCurrent 将 prefix 当前使用的字典设为 id，并关闭原来的编码器，调用者需持有写锁。
+func (d *dictionaries) setCurrent(prefix string, id uint32) error {
+	encoder, err := zstd.NewWriter(nil, zstd.WithEncoderDict(d.all[id]))
+	if err != nil {
+		return err
+	}
+	if old := d.encoders[prefix]; old != nil {
+		_ = old.Close()
+	}
+	d.encoders[prefix] = encoder
+	d.ids[prefix] = id
+	return nil
+}
+
+// compress 使用键 k 的最长匹配前缀当前使用的字典压缩 data。
+// 返回值:
+// []byte: compressDict 的结果。
+// bool: 是否有匹配的字典，没有时不压缩。
+func (d *dictionaries) compress(k, data []byte) ([]byte, bool) {
+	// 编码期间持有读锁，编码器不会在使用中被 setCurrent 或 reset 关闭。
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	var enc *zstd.Encoder
+	var id uint32
+	longest := -1
+	for prefix, e := range d.encoders {
+		if len(prefix) > longest && bytes.HasPrefix(k, []byte(prefix)) {
+			enc, id, longest = e, d.ids[prefix], len(prefix)
+		}
+	}
+	if enc == nil {
+		return nil, false
+	}
+	return compressDict(enc, id, data), true
+}
+
+// decode 解压使用字典 id 压缩的 zstd 帧。
+func (d *dictionaries) decode(id uint32, frame []byte) ([]byte, error) {
+	// 解码期间持有读锁，解码器不会在使用中被 add 或 reset 关闭。
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if _, ok := d.all[id]; !ok {
+		return nil, errors.Errorf("zstd dictionary %d not found", id)
+	}
+	out, err := d.decoder.DecodeAll(frame, nil)
+	return out, errors.Wrap(err, "zstd")
+}
+
+// compressDict 使用字典编码器压缩 data，结果为 ZstdDict + 字典 ID(4) + zstd 帧。
+func compressDict(enc *zstd.Encoder, id uint32, data []byte) []byte {
+	dst := binary.BigEndian.AppendUint32([]byte{byte(ZstdDict)}, id)
+	return enc.EncodeAll(data, dst)
+}
+
+// dictKey 返回字典 ID 的系统键。
+func dictKey(id uint32) []byte {
+	k := append(bytes.Clone(systemPrefix), "dict/"...)
+	return binary.BigEndian.AppendUint32(k, id)
+}
+
+// dictCurrentKey 返回前缀当前使用的字典 ID 的系统键。
+func dictCurrentKey(prefix []byte) []byte {
+	return append(append(bytes.Clone(systemPrefix), "dictcur/"...), prefix...)
+}
+
+// loadDictionaries 从数据库中加载所有字典。
+// 字典以明文保存并包含训练样本的片段，如果字典的前缀与 Options.SealedPrefixes 重叠，
+// 说明它是在前缀被密封之前训练的，备份中会出现密封前缀下的值的片段，此时返回错误。
+func (s *Store) loadDictionaries() error {
+	var entries []dictEntry
+	current := map[uint32]bool{}
+	if err := s.scan(dictCurrentKey(nil), ScanOptions{}, func(item *badger.Item) (bool, error) {
+		return true, item.Value(func(val []byte) error {
+			if len(val) != 4 {
+				return errors.New("invalid dictionary reference")
+			}
+			current[binary.BigEndian.Uint32(val)] = true
+			return nil
+		})
+	}); err != nil {
+		return err
+	}
+	if err := s.scan(dictKey(0)[:len(systemPrefix)+len("dict/")], ScanOptions{}, func(item *badger.Item) (bool, error) {
+		id := binary.BigEndian.Uint32(item.Key()[len(item.Key())-4:])
+		return true, item.Value(func(val []byte) error {
+			prefix, raw, err := readString(val)
+			if err != nil {
+				return err
+			}
+			if s.overlapsSealed([]byte(prefix)) {
+				return errors.Errorf("dictionary %d of prefix %q was trained before the prefix was sealed and holds plaintext samples", id, prefix)
+			}
+			entries = append(entries, dictEntry{prefix: prefix, id: id, raw: bytes.Clone(raw), current: current[id]})
+			return nil
+		})
+	}); err != nil {
+		return err
+	}
+	return s.dicts.add(entries...)
+}
+
+// TrainDictionary 从默认 Store 中以 prefix 开头的值里采样训练一个 zstd 字典，保存到数据库中，
+// 之后写入该前缀下的值时自动使用它。再次训练会生成新版本的字典，旧的值仍然可以读取。
+// 已有的值不会被重新压缩。
+// prefix: 键的前缀，编码方式与 Scan 相同。
+// maxSamples: 最多采样的值的数量，不大于 0 时使用 defaultDictSamples。
+// 返回值:
+// uint32: 新字典的 ID。
+// error: 操作中发生的任何错误，例如样本不足以训练字典。
+func TrainDictionary(prefix any, maxSamples int) (uint32, error) {
+	s, err := defaultStore()
+	if err != nil {
+		return 0, err
+	}
+	return s.TrainDictionary(prefix, maxSamples)
+}
+
+// TrainDictionary 训练并启用前缀的字典，语义与包级别的 TrainDictionary 相同。
+func (s *Store) TrainDictionary(prefix any, maxSamples int) (uint32, error) {
+	p, err := serializeKey(prefix)
+	if err != nil {
+		return 0, err
+	}
+	if maxSamples <= 0 {
+		maxSamples = defaultDictSamples
+	}
+	// 字典以明文保存并包含样本的片段，不能用密封前缀下的值训练。
+	if s.overlapsSealed(p) {
+		return 0, errors.Errorf("cannot train a dictionary on sealed prefix %q", p)
+	}
+
+	// 采样的是压缩之前的数据，与写入时交给压缩的数据一致。
+	var samples [][]byte
+	if err = s.scan(p, ScanOptions{Limit: maxSamples}, func(item *badger.Item) (bool, error) {
+		return true, item.Value(func(val []byte) error {
+			raw, err := s.rawValue(item.Key(), val, item.UserMeta())
+			if err != nil {
+				return corrupt(item, err)
+			}
+			samples = append(samples, bytes.Clone(raw))
+			return nil
+		})
+	}); err != nil {
+		return 0, err
+	}
+	if len(samples) == 0 {
+		return 0, errors.Errorf("no values under prefix %q to train a dictionary", p)
+	}
+
+	id, err := s.newDictID()
+	if err != nil {
+		return 0, err
+	}
+	raw, err := dict.BuildZstdDict(samples, dict.Options{
+		MaxDictSize: maxDictSize,
+		HashBytes:   6,
+		ZstdDictID:  id,
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "train dictionary")
+	}
+
+	record := appendString(nil, string(p))
+	record = append(record, raw...)
+	if err = s.db.Update(func(txn *badger.Txn) error {
+		if err := txn.Set(dictKey(id), record); err != nil {
+			return err
+		}
+		return txn.Set(dictCurrentKey(p), binary.BigEndian.AppendUint32(nil, id))
+	}); err != nil {
+		return 0, err
+	}
+	return id, s.dicts.add(dictEntry{prefix: string(p), id: id, raw: raw, current: true})
+}
+
+// newDictID 生成一个未被使用的字典 ID。zstd 保留了小于 32768 的 ID。
+func (s *Store) newDictID() (uint32, error) {
+	var b [4]byte
+	for {
+		if _, err := rand.Read(b[:]); err != nil {
+			return 0, err
+		}
+		id := 32768 + binary.BigEndian.Uint32(b[:])%(1<<31-32768)
+		s.dicts.mu.RLock()
+		_, used := s.dicts.all[id]
+		s.dicts.mu.RUnlock()
+		if !used {
+			return id, nil
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package kv
+
+import (
+	"fmt"
+	"testing"
+)
+
+// profile returns a small JSON-like value that compresses poorly on its own.
+func profile(i int) string {
+	return fmt.Sprintf(`{"id":%d,"name":"user-%d","email":"user-%d@example.com","locale":"zh-CN","plan":"free","verified":%v}`,
+		i, i*7919, i*104729, i%2 == 0)
+}
+
+func TestTrainDictionary(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(Options{Path: dir})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	for i := range 500 {
+		if err = s.Set(fmt.Sprintf("user:%d", i), profile(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	first, err := s.TrainDictionary("user:", 0)
+	if err != nil {
+		t.Fatalf("TrainDictionary() error = %v", err)
+	}
+	if err = s.Set("user:new", profile(1000)); err != nil {
+		t.Fatal(err)
+	}
+	size, meta := storedSize(t, s, "user:new")
+	if meta&metaCompressed == 0 || size >= len(profile(1000))*3/4 {
+		t.Errorf("stored %d bytes (meta %b) for %d input bytes, want dictionary compression", size, meta, len(profile(1000)))
+	}
+	if _, meta = storedSize(t, s, "user:1"); meta&metaCompressed != 0 {
+		t.Error("existing values should not be recompressed")
+	}
+
+	second, err := s.TrainDictionary("user:", 100)
+	if err != nil || second == first {
+		t.Fatalf("TrainDictionary() = %d, %v, want a new dictionary", second, err)
+	}
+	if err = s.Set("user:newer", profile(1001)); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Both dictionary versions are loaded again when the store is reopened.
+	if s, err = Open(Options{Path: dir}); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+	for key, want := range map[string]string{"user:new": profile(1000), "user:newer": profile(1001), "user:1": profile(1)} {
+		var got string
+		if _, err = s.Get(key, &got); err != nil || got != want {
+			t.Errorf("Get(%q) = %q, %v, want %q", key, got, err, want)
+		}
+	}
+
+	seq, done := ScanStore[string, string](s, "", ScanOptions{})
+	n := 0
+	for range seq {
+		n++
+	}
+	if err = done(); err != nil || n != 502 {
+		t.Errorf("Scan() returned %d entries, %v, want 502 without the dictionaries", n, err)
+	}
+}
+
+func TestTrainDictionary_Empty(t *testing.T) {
+	s, err := Open(Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	if _, err = s.TrainDictionary("nothing:", 0); err == nil {
+		t.Error("TrainDictionary() on an empty prefix should fail")
+	}
+}
+
+func TestOpen_RejectsZstdDict(t *testing.T) {
+	for name, opts := range map[string]Options{
+		"default": {Compression: ZstdDict},
+		"prefix":  {CompressionPrefixes: map[string]Compression{"user:": ZstdDict}},
+		"unknown": {Compression: 9},
+	} {
+		if s, err := Open(opts); err == nil {
+			_ = s.Close()
+			t.Errorf("%s: Open() should reject the compression", name)
+		}
+	}
+}
+
+// A dictionary trained before its prefix was sealed holds plaintext samples, so Open must refuse it.
+func TestOpen_DictionaryOnSealedPrefix(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(Options{Path: dir})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	for i := range 500 {
+		if err = s.Set(fmt.Sprintf("user:%d", i), profile(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err = s.TrainDictionary("user:", 0); err != nil {
+		t.Fatalf("TrainDictionary() error = %v", err)
+	}
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, prefix := range []string{"user:", "user:vip:", "us"} {
+		if s, err = Open(Options{Path: dir, MasterKey: masterKey, SealedPrefixes: []string{prefix}}); err == nil {
+			_ = s.Close()
+			t.Errorf("Open() with sealed prefix %q should fail", prefix)
+		}
+	}
+	if s, err = Open(Options{Path: dir, MasterKey: masterKey, SealedPrefixes: []string{"token:"}}); err != nil {
+		t.Fatalf("Open() with an unrelated sealed prefix error = %v", err)
+	}
+	_ = s.Close()
+}
@@ -0,0 +1,189 @@
+package kv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/pkg/errors"
+)
+
+/*
+静态加密使用 Badger 自身的加密：Options.EncryptionKey 是主密钥，Badger 用它加密数据密钥，
+再用数据密钥加密 SST 和值日志。用主密钥加密后的数据密钥保存在密钥注册表（KEYREGISTRY）中，
+因此轮换主密钥只需要重写密钥注册表，不需要重写数据。
+*/
+
+var (
+	// ErrWrongEncryptionKey 表示数据库目录使用了另一个加密密钥。
+	ErrWrongEncryptionKey = errors.New("wrong encryption key")
+	// ErrNotEncrypted 表示配置了加密密钥，但数据库目录没有加密。
+	ErrNotEncrypted = errors.New("database is not encrypted")
+	// ErrEncrypted 表示数据库目录已加密，但没有配置加密密钥。
+	ErrEncrypted = errors.New("database is encrypted but no encryption key is configured")
+	// ErrDatabaseInUse 表示数据库目录正在被打开，或者上次没有正常关闭。
+	ErrDatabaseInUse = errors.New("database is in use")
+)
+
+// badgerLockFile 是 Badger 以读写方式打开目录时写入的 pid 文件，正常关闭时删除。
+const badgerLockFile = "LOCK"
+
+// defaultIndexCacheSize 是启用加密且 Options.IndexCacheSize 不大于 0 时使用的索引缓存大小。
+// 加密的表每次访问索引都需要解密，Badger 建议此时设置索引缓存。
+const defaultIndexCacheSize = 100 << 20
+
+// registrySanity 是 Badger 写在密钥注册表开头的校验文本，未加密的注册表中以明文存放。
+var registrySanity = []byte("Hello Badger")
+
+// RotateEncryptionKey 将目录 path 中数据库的加密密钥从 oldKey 换成 newKey。
+// 它只重写密钥注册表，数据本身不需要重写。数据库必须处于关闭状态：
+// 目录被任何进程打开（包括只读打开），或者留有上次没有正常关闭的 LOCK 文件时返回 ErrDatabaseInUse，
+// 后一种情况需要先正常打开并关闭一次数据库。
+// path: 数据库目录，含义与 Options.Path 相同，不能为空。
+// oldKey: 当前的加密密钥。
+// newKey: 新的加密密钥，长度必须是 16、24 或 32 字节。
+// 返回值:
+// error: 操作中发生的任何错误，oldKey 不正确时返回 ErrWrongEncryptionKey。
+func RotateEncryptionKey(path string, oldKey, newKey []byte) error {
+	if path == "" {
+		return errors.New("cannot rotate the encryption key of an in-memory database")
+	}
+	if err := checkEncryptionKey(oldKey); err != nil {
+		return err
+	}
+	if err := checkEncryptionKey(newKey); err != nil {
+		return err
+	}
+	unlock, err := lockDir(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if _, err = os.Stat(filepath.Join(path, badgerLockFile)); err == nil {
+		return errors.Wrapf(ErrDatabaseInUse, "%s exists", filepath.Join(path, badgerLockFile))
+	}
+	opt := badger.KeyRegistryOptions{
+		Dir:                           path,
+		ReadOnly:                      true,
+		EncryptionKey:                 oldKey,
+		EncryptionKeyRotationDuration: badger.DefaultOptions(path).EncryptionKeyRotationDuration,
+	}
+	if _, err := os.Stat(filepath.Join(path, badger.KeyRegistryFileName)); err != nil {
+		return errors.Wrap(err, "open key registry")
+	}
+	kr, err := badger.OpenKeyRegistry(opt)
+	if err != nil {
+		return encryptionError(path, oldKey, err)
+	}
+	defer func() { _ = kr.Close() }()
+	opt.EncryptionKey = newKey
+	return errors.Wrap(badger.WriteKeyRegistry(kr, opt), "write key registry")
+}
+
+// checkEncryptionKey 检查 key 是否是合法的 AES 密钥。
+func checkEncryptionKey(key []byte) error {
+	switch len(key) {
+	case 16, 24, 32:
+		return nil
+	}
+	return errors.Errorf("encryption key must be 16, 24 or 32 bytes, got %d", len(key))
+}
+
+// encryptionError 将 Badger 打开目录 path 时与加密相关的错误转换为本包的错误，其他错误原样返回。
+// key 是打开时使用的加密密钥。
+func encryptionError(path string, key []byte, err error) error {
+	if errors.Is(err, badger.ErrInvalidEncryptionKey) {
+		return checkEncryptionKey(key)
+	}
+	if !errors.Is(err, badger.ErrEncryptionKeyMismatch) {
+		return err
+	}
+	encrypted, rerr := registryEncrypted(path)
+	switch {
+	case rerr != nil:
+		return errors.Wrapf(ErrWrongEncryptionKey, "open %s", path)
+	case !encrypted:
+		return errors.Wrapf(ErrNotEncrypted, "open %s", path)
+	case len(key) == 0:
+		return errors.Wrapf(ErrEncrypted, "open %s", path)
+	}
+	return errors.Wrapf(ErrWrongEncryptionKey, "open %s", path)
+}
+
+// registryEncrypted 判断目录 path 中的密钥注册表是否已加密。
+// 注册表以 AES 块大小的 IV 开头，之后是校验文本，未加密时校验文本是明文。
+func registryEncrypted(path string) (bool, error) {
+	f, err := os.Open(filepath.Join(path, badger.KeyRegistryFileName))
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = f.Close() }()
+	head := make([]byte, aes.BlockSize+len(registrySanity))
+	if _, err = io.ReadFull(f, head); err != nil {
+		return false, err
+	}
+	return !bytes.Equal(head[aes.BlockSize:], registrySanity), nil
+}
+
+// loadKey 按配置项读取密钥，name 是配置项名称的公共部分，例如 "CACHE ENCRYPTION KEY"。
+// file 是密钥文件的路径，env 是保存密钥的环境变量名，两者最多只能设置一个，都为空时返回 nil。
+// 内容的格式见 parseEncryptionKey，例如 "hex:" 加 64 个十六进制字符表示 32 字节的密钥。
+func loadKey(name, file, env string) ([]byte, error) {
+	switch {
+	case file != "" && env != "":
+		return nil, errors.Errorf("%s FILE and %s ENV are mutually exclusive", name, name)
+	case file != "":
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "read %s FILE", name)
+		}
+		key, err := parseEncryptionKey(data)
+		return key, errors.Wrapf(err, "%s FILE %s", name, file)
+	case env != "":
+		value, ok := os.LookupEnv(env)
+		if !ok || value == "" {
+			return nil, errors.Errorf("environment variable %s of %s ENV is not set", env, name)
+		}
+		key, err := parseEncryptionKey([]byte(value))
+		return key, errors.Wrapf(err, "environment variable %s", env)
+	}
+	return nil, nil
+}
+
+// hexKeyPrefix 是十六进制编码的密钥的前缀。
+const hexKeyPrefix = "hex:"
+
+// parseEncryptionKey 解析密钥文件或环境变量的内容，编码必须显式指定：
+// 以 "hex:" 开头时，其余部分去掉首尾空白后按十六进制解码；否则内容原样作为密钥，不去掉空白。
+func parseEncryptionKey(data []byte) ([]byte, error) {
+	if text, ok := bytes.CutPrefix(data, []byte(hexKeyPrefix)); ok {
+		key, err := hex.DecodeString(string(bytes.TrimSpace(text)))
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid hex key")
+		}
+		data = key
+	}
+	if err := checkEncryptionKey(data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// withEncryption 将 opts 中的加密选项应用到 Badger 的选项上。
+func withEncryption(opt badger.Options, opts Options) badger.Options {
+	if len(opts.EncryptionKey) == 0 {
+		if opts.IndexCacheSize > 0 {
+			opt = opt.WithIndexCacheSize(opts.IndexCacheSize)
+		}
+		return opt
+	}
+	size := opts.IndexCacheSize
+	if size <= 0 {
+		size = defaultIndexCacheSize
+	}
+	return opt.WithEncryptionKey(opts.EncryptionKey).WithIndexCacheSize(size)
+}
@@ -0,0 +1,150 @@
+package kv
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var (
+	testKey  = bytes.Repeat([]byte{1}, 32)
+	otherKey = bytes.Repeat([]byte{2}, 16)
+)
+
+// setAndClose opens dir with key, writes one value and closes the store.
+func setAndClose(t *testing.T, dir string, key []byte) {
+	t.Helper()
+	s, err := Open(Options{Path: dir, EncryptionKey: key})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if err = s.Set("secret", "pii"); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEncryption_Errors(t *testing.T) {
+	encrypted, plain := t.TempDir(), t.TempDir()
+	setAndClose(t, encrypted, testKey)
+	setAndClose(t, plain, nil)
+
+	for _, tt := range []struct {
+		name string
+		dir  string
+		key  []byte
+		want error
+	}{
+		{"wrong key", encrypted, otherKey, ErrWrongEncryptionKey},
+		{"missing key", encrypted, nil, ErrEncrypted},
+		{"unencrypted directory", plain, testKey, ErrNotEncrypted},
+	} {
+		s, err := Open(Options{Path: tt.dir, EncryptionKey: tt.key})
+		if err == nil {
+			_ = s.Close()
+		}
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: Open() error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	if _, err := Open(Options{Path: t.TempDir(), EncryptionKey: []byte("short")}); err == nil {
+		t.Error("Open() with a 5 byte key should fail")
+	}
+}
+
+func TestRotateEncryptionKey(t *testing.T) {
+	dir := t.TempDir()
+	setAndClose(t, dir, testKey)
+
+	if err := RotateEncryptionKey(dir, otherKey, testKey); !errors.Is(err, ErrWrongEncryptionKey) {
+		t.Errorf("RotateEncryptionKey() with a wrong old key error = %v", err)
+	}
+	if err := RotateEncryptionKey(dir, testKey, otherKey); err != nil {
+		t.Fatalf("RotateEncryptionKey() error = %v", err)
+	}
+
+	if _, err := Open(Options{Path: dir, EncryptionKey: testKey}); !errors.Is(err, ErrWrongEncryptionKey) {
+		t.Errorf("Open() with the old key error = %v, want ErrWrongEncryptionKey", err)
+	}
+	s, err := Open(Options{Path: dir, EncryptionKey: otherKey})
+	if err != nil {
+		t.Fatalf("Open() with the new key error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+	var got string
+	if ok, err := s.Get("secret", &got); err != nil || !ok || got != "pii" {
+		t.Errorf("Get() = %q, %v, %v, want pii", got, ok, err)
+	}
+}
+
+func TestRotateEncryptionKey_InUse(t *testing.T) {
+	dir := t.TempDir()
+	setAndClose(t, dir, testKey)
+
+	for _, readOnly := range []bool{false, true} {
+		s, err := Open(Options{Path: dir, EncryptionKey: testKey, ReadOnly: readOnly})
+		if err != nil {
+			t.Fatalf("Open(ReadOnly=%v) error = %v", readOnly, err)
+		}
+		if err = RotateEncryptionKey(dir, testKey, otherKey); !errors.Is(err, ErrDatabaseInUse) {
+			t.Errorf("RotateEncryptionKey() on an open store (ReadOnly=%v) error = %v, want ErrDatabaseInUse", readOnly, err)
+		}
+		_ = s.Close()
+	}
+
+	// A LOCK file left by a crashed process also blocks rotation.
+	lock := filepath.Join(dir, badgerLockFile)
+	if err := os.WriteFile(lock, []byte("1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := RotateEncryptionKey(dir, testKey, otherKey); !errors.Is(err, ErrDatabaseInUse) {
+		t.Errorf("RotateEncryptionKey() with a stale LOCK error = %v, want ErrDatabaseInUse", err)
+	}
+	if err := os.Remove(lock); err != nil {
+		t.Fatal(err)
+	}
+	if err := RotateEncryptionKey(dir, testKey, otherKey); err != nil {
+		t.Errorf("RotateEncryptionKey() after closing error = %v", err)
+	}
+}
+
+func TestLoadKey(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(file, []byte("hex:"+hex.EncodeToString(testKey)+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if key, err := loadKey("CACHE ENCRYPTION KEY", file, ""); err != nil || !bytes.Equal(key, testKey) {
+		t.Errorf("loadKey(hex file) = %x, %v", key, err)
+	}
+
+	t.Setenv("KV_TEST_KEY", "0123456789abcdef")
+	if key, err := loadKey("CACHE ENCRYPTION KEY", "", "KV_TEST_KEY"); err != nil || string(key) != "0123456789abcdef" {
+		t.Errorf("loadKey(raw env) = %q, %v", key, err)
+	}
+
+	// Without the prefix the content is the key itself, even if it looks like hex.
+	t.Setenv("KV_TEST_KEY", hex.EncodeToString(otherKey))
+	if key, err := loadKey("CACHE ENCRYPTION KEY", "", "KV_TEST_KEY"); err != nil || string(key) != hex.EncodeToString(otherKey) {
+		t.Errorf("loadKey(unprefixed hex env) = %q, %v, want the raw text", key, err)
+	}
+	t.Setenv("KV_TEST_KEY", "hex:zz")
+	if _, err := loadKey("CACHE ENCRYPTION KEY", "", "KV_TEST_KEY"); err == nil {
+		t.Error("loadKey() with invalid hex should fail")
+	}
+
+	if _, err := loadKey("CACHE ENCRYPTION KEY", file, "KV_TEST_KEY"); err == nil {
+		t.Error("loadKey() with both sources should fail")
+	}
+	if _, err := loadKey("CACHE ENCRYPTION KEY", "", "KV_TEST_MISSING"); err == nil {
+		t.Error("loadKey() with an unset variable should fail")
+	}
+	if key, err := loadKey("CACHE ENCRYPTION KEY", "", ""); err != nil || key != nil {
+		t.Errorf("loadKey() without configuration = %x, %v", key, err)
+	}
+}
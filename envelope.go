@@ -0,0 +1,292 @@
+package kv
+
+import (
+	"encoding/binary"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/dgraph-io/badger/v4"
+	"github.com/pkg/errors"
+)
+
+/*
+信封 (envelope) 是写在值前面的自描述头部，记录写入时使用的编解码器、值的类型指纹和格式版本。
+是否带有信封由 Badger 条目的 UserMeta 中的 metaEnvelope 位标记，而不是靠猜测值的内容，
+因此没有信封的旧数据仍然按原来的方式读取。
+
+信封头部布局（大端）：
+  - [0]   格式版本，当前为 envelopeVersion。
+  - [1]   编解码器 ID，0 表示自定义编解码器。
+  - [2]   保留，必须为 0。
+  - [3:7] 类型指纹，值类型的完整名称（见 typeName）的 xxhash 的低 32 位。
+*/
+
+// metaEnvelope 是 UserMeta 中表示值带有信封的位。
+const metaEnvelope byte = 1 << 0
+
+const (
+	// envelopeVersion 是当前的信封格式版本。
+	envelopeVersion byte = 1
+	// envelopeSize 是信封头部的字节数。
+	envelopeSize = 7
+)
+
+// ErrTypeMismatch 表示存储的值的类型与读取时期望的类型不一致。
+var ErrTypeMismatch = errors.New("type mismatch")
+
+// codecID 返回内置编解码器的 ID，自定义编解码器返回 0。
+// 信封中记录了 ID，读取时可以自动选择写入时使用的编解码器。
+func codecID(c Codec) byte {
+	switch c.(type) {
+	case hybridCodec:
+		return 1
+	case jsonCodec:
+		return 2
+	case gobCodec:
+		return 3
+	case msgpackCodec:
+		return 4
+	}
+	return 0
+}
+
+// codecByID 返回 ID 对应的内置编解码器，未知的 ID 返回 nil。
+func codecByID(id byte) Codec {
+	switch id {
+	case 1:
+		return HybridCodec
+	case 2:
+		return JSONCodec
+	case 3:
+		return GobCodec
+	case 4:
+		return MsgpackCodec
+	}
+	return nil
+}
+
+// envelope 是解析后的信封头部。
+type envelope struct {
+	version     byte
+	codec       byte
+	fingerprint uint32
+}
+
+// matches 判断信封中的类型指纹是否与类型 t 一致。
+func (env envelope) matches(t reflect.Type) bool {
+	return env.fingerprint == typeFingerprint(t)
+}
+
+// typeFingerprint 计算类型的指纹。指针类型与其元素类型的指纹相同。
+func typeFingerprint(t reflect.Type) uint32 {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return uint32(xxhash.Sum64String(typeName(t)))
+}
+
+// typeName 返回类型的完整名称。命名类型使用 包路径.名称，复合类型递归展开其元素类型，
+// 因此不同包中的同名类型，以及以它们为元素的切片、map 等，名称都不相同。
+func typeName(t reflect.Type) string {
+	if t.Name() != "" {
+		if t.PkgPath() == "" {
+			return t.Name() // 预声明类型，如 int、string、error
+		}
+		return t.PkgPath() + "." + t.Name()
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return "*" + typeName(t.Elem())
+	case reflect.Slice:
+		return "[]" + typeName(t.Elem())
+	case reflect.Array:
+		return "[" + strconv.Itoa(t.Len()) + "]" + typeName(t.Elem())
+	case reflect.Map:
+		return "map[" + typeName(t.Key()) + "]" + typeName(t.Elem())
+	case reflect.Chan:
+		return t.ChanDir().String() + " " + typeName(t.Elem())
+	case reflect.Struct:
+		var b strings.Builder
+		b.WriteString("struct {")
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if i > 0 {
+				b.WriteString(";")
+			}
+			b.WriteString(" ")
+			if !f.Anonymous {
+				b.WriteString(f.Name + " ")
+			}
+			b.WriteString(typeName(f.Type))
+			if f.Tag != "" {
+				b.WriteString(" " + strconv.Quote(string(f.Tag)))
+			}
+		}
+		b.WriteString(" }")
+		return b.String()
+	}
+	// 函数和匿名接口不会作为值存储，使用 reflect 的表示即可。
+	return t.String()
+}
+
+// appendEnvelope 在 payload 前加上信封头部。
+func appendEnvelope(env envelope, payload []byte) []byte {
+	buf := make([]byte, envelopeSize, envelopeSize+len(payload))
+	buf[0] = env.version
+	buf[1] = env.codec
+	binary.BigEndian.PutUint32(buf[3:7], env.fingerprint)
+	return append(buf, payload...)
+}
+
+// parseEnvelope 解析信封头部，返回头部和剩余的数据。
+func parseEnvelope(data []byte) (envelope, []byte, error) {
+	var env envelope
+	if len(data) < envelopeSize {
+		return env, nil, errors.New("insufficient data for envelope")
+	}
+	env.version = data[0]
+	if env.version == 0 || env.version > envelopeVersion {
+		return env, nil, errors.Errorf("unsupported envelope version %d", env.version)
+	}
+	env.codec = data[1]
+	if data[2] != 0 {
+		return env, nil, errors.Errorf("unsupported envelope flags 0x%02x", data[2])
+	}
+	env.fingerprint = binary.BigEndian.Uint32(data[3:7])
+	return env, data[envelopeSize:], nil
+}
+
+// marshal 使用 Store 的编解码器序列化键 k 的值，nil 值序列化为 nil。
+// 如果值的类型注册了迁移函数，则在值前加上 schema 版本字节。
+// 然后按键的压缩配置压缩，键属于密封的前缀时加密，如果启用了 Options.Envelope，再在最前面加上信封头部。
+// 返回值:
+// []byte: 序列化后的字节切片。
+// byte: 写入 Badger 条目的 UserMeta。
+// error: 序列化过程中发生的任何错误。
+func (s *Store) marshal(k []byte, value any) ([]byte, byte, error) {
+	if value == nil {
+		return nil, 0, nil
+	}
+	data, err := s.codec.Marshal(value)
+	if err != nil || data == nil {
+		return data, 0, err
+	}
+	var meta byte
+	if _, ok := s.codec.(hybridCodec); ok {
+		meta |= metaNative
+	}
+	if sc := schemaOf(reflect.TypeOf(value)); sc != nil {
+		data = append([]byte{sc.version}, data...)
+		meta |= metaVersioned
+	}
+	data, compressed, err := s.compressValue(k, data)
+	if err != nil {
+		return nil, 0, err
+	}
+	if compressed {
+		meta |= metaCompressed
+	}
+	data, sealed, err := s.seal(k, k, data)
+	if err != nil {
+		return nil, 0, err
+	}
+	if sealed {
+		meta |= metaSealed
+	}
+	if !s.opts.Envelope {
+		return data, meta, nil
+	}
+	env := envelope{
+		version:     envelopeVersion,
+		codec:       codecID(s.codec),
+		fingerprint: typeFingerprint(reflect.TypeOf(value)),
+	}
+	return appendEnvelope(env, data), meta | metaEnvelope, nil
+}
+
+// unmarshal 将条目的值 data 解码到 value 指向的变量。
+// 如果条目的 UserMeta 表示值带有信封，则校验类型指纹，并使用写入时的内置编解码器解码；
+// 否则使用 Store 的编解码器。旧版本的值先迁移到当前版本再解码。
+// nil 数据表示存储的是 nil 值，此时 value 指向的变量被置为零值。
+// 信封、密封或压缩的数据损坏，以及值的长度与类型不符时返回 *CorruptValueError，其他解码错误原样返回。
+func (s *Store) unmarshal(item *badger.Item, data []byte, value any) error {
+	_, err := s.decode(item, data, value)
+	return err
+}
+
+// decode 与 unmarshal 相同，返回的 bool 表示值是否从旧版本迁移而来。
+func (s *Store) decode(item *badger.Item, data []byte, value any) (bool, error) {
+	codec := s.codec
+	meta := item.UserMeta()
+	if meta&metaStream != 0 {
+		return false, ErrStreamValue
+	}
+	t := reflect.TypeOf(value).Elem()
+	if meta&metaEnvelope != 0 {
+		env, payload, err := parseEnvelope(data)
+		if err != nil {
+			return false, corrupt(item, err)
+		}
+		if t.Kind() != reflect.Interface && !env.matches(t) {
+			return false, errors.Wrapf(ErrTypeMismatch, "cannot decode into %s", t)
+		}
+		if c := codecByID(env.codec); c != nil {
+			codec = s.strictCodec(c)
+		}
+		data = payload
+	}
+	if meta&metaSealed != 0 {
+		var err error
+		if data, err = s.unseal(item.Key(), item.Key(), data); err != nil {
+			return false, corrupt(item, err)
+		}
+	}
+	if meta&metaCompressed != 0 {
+		var err error
+		if data, err = s.decompress(data); err != nil {
+			return false, corrupt(item, err)
+		}
+	}
+	codec = formatCodec(codec, meta)
+	data, migrated, err := migrate(codec, t, data, meta)
+	if err != nil {
+		return false, decodeError(item, err)
+	}
+	if data == nil {
+		reflect.ValueOf(value).Elem().SetZero()
+		return migrated, nil
+	}
+	if err = codec.Unmarshal(data, value); err != nil {
+		return false, decodeError(item, err)
+	}
+	return migrated, nil
+}
+
+// unmarshalValue 将条目的值 data 解码为类型 V 的值，规则与 Store.unmarshal 相同。
+func unmarshalValue[V any](s *Store, item *badger.Item, data []byte) (V, error) {
+	var value V
+	err := s.unmarshal(item, data, &value)
+	return value, err
+}
+
+// rawValue 去掉键 k 的值 data 的信封头部，解密并解压，返回值的原始编码（可能仍带有 schema 版本字节）。
+// 没有信封、没有密封也没有压缩时原样返回。
+func (s *Store) rawValue(k, data []byte, meta byte) ([]byte, error) {
+	var err error
+	if meta&metaEnvelope != 0 {
+		if _, data, err = parseEnvelope(data); err != nil {
+			return nil, err
+		}
+	}
+	if meta&metaSealed != 0 {
+		if data, err = s.unseal(k, k, data); err != nil {
+			return nil, err
+		}
+	}
+	if meta&metaCompressed != 0 {
+		return s.decompress(data)
+	}
+	return data, nil
+}
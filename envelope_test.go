@@ -0,0 +1,76 @@
+package kv
+
+import (
+	"errors"
+	htmltemplate "html/template"
+	"reflect"
+	"testing"
+	texttemplate "text/template"
+)
+
+func TestEnvelope_TypeMismatch(t *testing.T) {
+	s, err := Open(Options{Envelope: true})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	if err = s.Set("env_key", int32(7)); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	var wrong int64
+	if _, err = s.Get("env_key", &wrong); !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("Get() into int64 error = %v, want ErrTypeMismatch", err)
+	}
+	var right int32
+	if _, err = s.Get("env_key", &right); err != nil || right != 7 {
+		t.Errorf("Get() into int32 = %v, %v, want 7", right, err)
+	}
+	var ptr *int32
+	if _, err = s.Get("env_key", &ptr); err != nil || ptr == nil || *ptr != 7 {
+		t.Errorf("Get() into *int32 = %v, %v, want 7", ptr, err)
+	}
+}
+
+func TestEnvelope_LegacyAndCodec(t *testing.T) {
+	s, err := Open(Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	// Values written without an envelope stay readable once it is enabled.
+	if err = s.Set("legacy_key", "legacy"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	enveloped := s.WithCodec(HybridCodec)
+	enveloped.opts.Envelope = true
+	var got string
+	if _, err = enveloped.Get("legacy_key", &got); err != nil || got != "legacy" {
+		t.Errorf("Get() legacy = %v, %v, want legacy", got, err)
+	}
+
+	// The envelope records the codec, so a JSON value decodes through a hybrid store.
+	jsonView := enveloped.WithCodec(JSONCodec)
+	if err = jsonView.Set("json_key", codecUser{Name: "carol", Age: 40}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	var user codecUser
+	if _, err = s.Get("json_key", &user); err != nil || user.Name != "carol" {
+		t.Errorf("Get() json = %+v, %v, want carol", user, err)
+	}
+}
+
+func TestTypeFingerprint_PackagePath(t *testing.T) {
+	// Both types print as "template.Template" but live in different packages.
+	text, html := reflect.TypeOf(texttemplate.Template{}), reflect.TypeOf(htmltemplate.Template{})
+	if typeFingerprint(text) == typeFingerprint(html) {
+		t.Error("same-named types from different packages share a fingerprint")
+	}
+	if typeFingerprint(reflect.SliceOf(text)) == typeFingerprint(reflect.SliceOf(html)) {
+		t.Error("slices of same-named types share a fingerprint")
+	}
+	if typeFingerprint(reflect.TypeOf(&codecUser{})) != typeFingerprint(reflect.TypeOf(codecUser{})) {
+		t.Error("pointer and element types should share a fingerprint")
+	}
+}
@@ -5,5 +5,7 @@ const prefix = "kv"
 
 func init() {
 	config()
-	start()
+	if startMode == "" || startMode == startEager {
+		start()
+	}
 }
@@ -0,0 +1,115 @@
+package kv
+
+import (
+	"reflect"
+)
+
+var (
+	tupleType        = reflect.TypeOf(Tuple(nil))
+	orderedIntType   = reflect.TypeOf(OrderedInt(0))
+	orderedFloatType = reflect.TypeOf(OrderedFloat(0))
+)
+
+// OrderedInt 是编码后字节序与数值大小一致的整数键，负数排在正数之前，用于按数值范围扫描的键。
+// 普通的 int、int64 等键保持原来的补码编码，已有数据库中的键不受影响；
+// 两种编码互不兼容，同一组键应始终使用同一种类型。
+type OrderedInt int64
+
+// OrderedFloat 是编码后字节序与数值大小一致的浮点数键，规则同 OrderedInt。
+type OrderedFloat float64
+
+// serializeKey 函数将键序列化为字节切片。
+// OrderedInt 翻转符号位，OrderedFloat 对负数取反全部位、对正数翻转符号位，
+// 这样 Badger 按字节序排列的键也就按数值大小排列，范围扫描才能正确工作。
+// Tuple 类型的键使用 Tuple.Pack 编码，其他类型使用 serializeLegacy 编码，与旧版本写入的键保持一致。
+// T 是泛型参数，代表任意类型的键。
+// key: 需要被序列化的键。
+// 返回值:
+// []byte: 序列化后的字节切片。
+// error: 序列化过程中发生的任何错误。
+func serializeKey[T any](key T) ([]byte, error) {
+	if t, ok := any(key).(Tuple); ok {
+		return t.Pack()
+	}
+	data, err := serializeLegacy(key)
+	if err != nil || data == nil {
+		return data, err
+	}
+	t := reflect.TypeOf(key)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == orderedIntType || t == orderedFloatType {
+		orderBytes(data, t.Kind(), true)
+	}
+	return data, nil
+}
+
+// deserializeKey 函数将 serializeKey 生成的字节切片反序列化为指定类型的键。
+// T 是泛型参数，代表目标键类型。
+// data: 需要被反序列化的字节切片。
+// 返回值:
+// T: 反序列化后的键。
+// error: 反序列化过程中发生的任何错误。
+func deserializeKey[T any](data []byte) (T, error) {
+	var result T
+	if data == nil {
+		return result, nil
+	}
+	value, err := deserializeKeyType(data, reflect.TypeOf(result))
+	if err != nil {
+		return result, err
+	}
+	result = value.Interface().(T)
+	return result, nil
+}
+
+// deserializeKeyType 将 serializeKey 生成的字节切片反序列化为类型 t 的 reflect.Value。
+func deserializeKeyType(data []byte, t reflect.Type) (reflect.Value, error) {
+	if t == tupleType {
+		tuple, err := Unpack(data)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(tuple), nil
+	}
+	e := t
+	if e.Kind() == reflect.Ptr {
+		e = e.Elem()
+	}
+	if e != orderedIntType && e != orderedFloatType {
+		return deserializeLegacy(data, t, false)
+	}
+	// 复制一份，避免修改调用者（例如 Badger 迭代器）持有的缓冲区。
+	buf := append([]byte(nil), data...)
+	orderBytes(buf, e.Kind(), false)
+	return deserializeLegacy(buf, t, false)
+}
+
+// orderBytes 原地转换数值类型的大端字节，使其字节序与数值大小一致。
+// encode 为 true 时执行编码方向的转换，为 false 时执行解码方向的转换。
+// 非数值类型以及无符号整数保持不变。
+func orderBytes(data []byte, k reflect.Kind, encode bool) {
+	if len(data) == 0 {
+		return
+	}
+	switch k {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int, reflect.Int64:
+		// 翻转符号位，使负数排在正数之前。
+		data[0] ^= 0x80
+	case reflect.Float32, reflect.Float64:
+		// 编码时看原始符号位，解码时看转换后的符号位：
+		// 负数取反全部位，使绝对值越大的负数越靠前；正数只翻转符号位。
+		negative := data[0]&0x80 != 0
+		if !encode {
+			negative = !negative
+		}
+		if negative {
+			for i := range data {
+				data[i] = ^data[i]
+			}
+		} else {
+			data[0] ^= 0x80
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package kv
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestSerializeKey_IntOrder(t *testing.T) {
+	nums := []OrderedInt{math.MinInt64, -1000, -1, 0, 1, 1000, math.MaxInt64}
+	var prev []byte
+	for _, n := range nums {
+		got, err := serializeKey(n)
+		if err != nil {
+			t.Fatalf("serializeKey(%d) error = %v", n, err)
+		}
+		if prev != nil && bytes.Compare(prev, got) >= 0 {
+			t.Errorf("serializeKey(%d) does not sort after its predecessor", n)
+		}
+		prev = got
+
+		back, err := deserializeKey[OrderedInt](got)
+		if err != nil {
+			t.Fatalf("deserializeKey() error = %v", err)
+		}
+		if back != n {
+			t.Errorf("deserializeKey() = %d, want %d", back, n)
+		}
+	}
+}
+
+func TestSerializeKey_FloatOrder(t *testing.T) {
+	nums := []OrderedFloat{OrderedFloat(math.Inf(-1)), -1e10, -1.5, -math.SmallestNonzeroFloat64, 0, math.SmallestNonzeroFloat64, 1.5, 1e10, OrderedFloat(math.Inf(1))}
+	var prev []byte
+	for _, f := range nums {
+		got, err := serializeKey(f)
+		if err != nil {
+			t.Fatalf("serializeKey(%v) error = %v", f, err)
+		}
+		if prev != nil && bytes.Compare(prev, got) >= 0 {
+			t.Errorf("serializeKey(%v) does not sort after its predecessor", f)
+		}
+		prev = got
+
+		back, err := deserializeKey[OrderedFloat](got)
+		if err != nil {
+			t.Fatalf("deserializeKey() error = %v", err)
+		}
+		if back != f {
+			t.Errorf("deserializeKey() = %v, want %v", back, f)
+		}
+	}
+}
+
+func TestOrderedIntKey_Order(t *testing.T) {
+	if bytes.Compare(OrderedIntKey(-1), OrderedIntKey(1)) >= 0 {
+		t.Errorf("OrderedIntKey(-1) should sort before OrderedIntKey(1)")
+	}
+	if bytes.Compare(OrderedFloatKey(-2.5), OrderedFloatKey(-1.5)) >= 0 {
+		t.Errorf("OrderedFloatKey(-2.5) should sort before OrderedFloatKey(-1.5)")
+	}
+	k, _ := serializeKey(OrderedInt(-7))
+	if !bytes.Equal(k, OrderedIntKey(-7)) {
+		t.Errorf("serializeKey(OrderedInt(-7)) = %x, want %x", k, OrderedIntKey(-7))
+	}
+}
+
+// Plain numeric keys keep the two's-complement layout used by existing databases.
+func TestSerializeKey_LegacyLayout(t *testing.T) {
+	k, err := serializeKey(int64(-1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(k, bytes.Repeat([]byte{0xff}, 8)) || !bytes.Equal(IntKey(-1), k) {
+		t.Errorf("serializeKey(int64(-1)) = %x, IntKey(-1) = %x, want ffffffffffffffff", k, IntKey(-1))
+	}
+	f, _ := serializeKey(1.5)
+	if !bytes.Equal(f, []byte{0x3f, 0xf8, 0, 0, 0, 0, 0, 0}) {
+		t.Errorf("serializeKey(1.5) = %x, want raw IEEE bits", f)
+	}
+	if back, err := deserializeKey[int64](k); err != nil || back != -1 {
+		t.Errorf("deserializeKey() = %d, %v, want -1", back, err)
+	}
+}
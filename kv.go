@@ -2,84 +2,44 @@ package kv
 
 import (
 	"encoding/binary"
-	"os"
-	"path"
-	"path/filepath"
+	"reflect"
 	"time"
 
-	pcolor "github.com/clong1995/go-ansi-color"
 	"github.com/pkg/errors"
 
 	"github.com/cespare/xxhash/v2"
 	"github.com/dgraph-io/badger/v4"
 )
 
-// db 是一个全局的 Badger 数据库连接实例。
-var db *badger.DB
-
-// start 函数在包被导入时自动执行。
-// 它负责初始化 Badger 数据库连接。
-// 数据库路径可以通过 "CACHE PATH" 配置项来设置。
-// 如果没有配置，它默认在当前执行文件的目录下创建一个 ".kv" 文件夹作为数据库路径。
-// 如果路径为空字符串，则使用内存模式。
-func start() {
-	// 从配置中读取缓存路径。
-	// 如果路径是 "./"，则解析为当前可执行文件的目录。
-	if cachePath == "./" {
-		exePath, err := os.Executable()
-		if err != nil {
-			pcolor.PrintFatal(prefix, err.Error())
-			return
-		}
-		cachePath = filepath.Dir(exePath)
-		cachePath = path.Join(cachePath, ".kv")
-	}
-	var err error
-	// 设置 Badger 数据库选项。如果 cachePath 为空，则使用内存数据库。
-	opt := badger.DefaultOptions(cachePath).WithInMemory(cachePath == "")
-	// 禁用 Badger 的默认日志记录器，以避免不必要的输出。
-	opt.Logger = nullLogger{}
-	// 打开 Badger 数据库。
-	if db, err = badger.Open(opt); err != nil {
-		pcolor.PrintFatal(prefix, err.Error())
-		return
-	}
-	// 打印连接成功的消息。
-	if cachePath == "" {
-		pcolor.PrintSucc(prefix, "conn in memory")
-	} else {
-		pcolor.PrintSucc(prefix, "conn %v", cachePath)
+// newEntry 创建一个 Badger 条目，设置 UserMeta，如果提供了 ttl 则设置过期时间。
+func newEntry(k, v []byte, meta byte, ttl []int64) *badger.Entry {
+	entry := badger.NewEntry(k, v).WithMeta(meta)
+	if len(ttl) > 0 {
+		entry.WithTTL(time.Duration(ttl[0]) * time.Millisecond)
 	}
+	return entry
 }
 
 // Set 将键值对存入数据库，可选择性地设置生存时间 (TTL)。
-// K, V 是泛型参数，代表任意类型的键和值。
 // key: 键。
 // value: 值。
 // ttl: 可选参数，生命周期，单位毫秒。
-func Set[K, V any](key K, value V, ttl ...int64) error {
+func (s *Store) Set(key, value any, ttl ...int64) error {
 	// 序列化键。
-	k, err := serialize[K](key)
+	k, err := serializeKey(key)
 	if err != nil {
 		return err
 	}
 	// 序列化值。
-	var v []byte
-	if any(value) != nil {
-		if v, err = serialize[V](value); err != nil {
-			return err
-		}
+	v, meta, err := s.marshal(k, value)
+	if err != nil {
+		return err
 	}
 
 	// 执行数据库更新操作。
-	if err = db.Update(func(txn *badger.Txn) error {
-		entry := badger.NewEntry(k, v)
-		// 如果设置了 TTL，则为条目添加过期时间。
-		if ttl != nil && len(ttl) > 0 {
-			entry.WithTTL(time.Duration(ttl[0]) * time.Millisecond)
-		}
-		// 设置条目。
-		if err = txn.SetEntry(entry); err != nil {
+	if err = s.db.Update(func(txn *badger.Txn) error {
+		// 设置条目。如果设置了 TTL，则为条目添加过期时间。
+		if err = txn.SetEntry(newEntry(k, v, meta, ttl)); err != nil {
 			return err
 		}
 		return nil
@@ -90,26 +50,31 @@ func Set[K, V any](key K, value V, ttl ...int64) error {
 	return nil
 }
 
-// Get 从数据库中获取一个值，并可选择性地更新其生存时间 (TTL)。
-// K, V 是泛型参数，代表任意类型的键和值。
+// Get 从数据库中获取一个值并解码到 value 指向的变量，可选择性地更新其生存时间 (TTL)。
 // key: 键。
+// value: 接收结果的指针，不能为 nil。
 // ttl: 可选参数，生命周期，单位毫秒。如果提供，将更新键的 TTL。
 // 返回值:
-// V: 获取到的值。
 // bool: 表示键是否存在。
 // error: 操作中发生的任何错误。
-func Get[K, V any](key K, ttl ...int64) (V, bool, error) {
-	var value V
+func (s *Store) Get(key, value any, ttl ...int64) (bool, error) {
+	if out := reflect.ValueOf(value); out.Kind() != reflect.Ptr || out.IsNil() {
+		return false, errors.New("value must be a non-nil pointer")
+	}
+
 	// 序列化键。
-	k, err := serialize[K](key)
+	k, err := serializeKey(key)
 	if err != nil {
-		return value, false, err
+		return false, err
 	}
 
 	exists := true
+	// migrated 表示读到的值是从旧的 schema 版本迁移而来，version 是读到的条目的 Badger 版本。
+	var migrated bool
+	var version uint64
 
 	// 判断是否需要续期，如果提供了 ttl 参数，则需要读写事务。
-	rw := ttl != nil && len(ttl) > 0
+	rw := len(ttl) > 0
 
 	// 定义获取值的核心逻辑。
 	getFunc := func(txn *badger.Txn) error {
@@ -125,15 +90,11 @@ func Get[K, V any](key K, ttl ...int64) (V, bool, error) {
 		}
 
 		// 获取条目的值。
-		if err = item.Value(func(val []byte) error {
-			if val == nil {
-				return nil
-			}
+		version = item.Version()
+		if err = item.Value(func(val []byte) (err error) {
 			// 反序列化值。
-			if value, err = deserialize[V](val); err != nil {
-				return err
-			}
-			return nil
+			migrated, err = s.decode(item, val, value)
+			return err
 		}); err != nil {
 			return err
 		}
@@ -142,19 +103,23 @@ func Get[K, V any](key K, ttl ...int64) (V, bool, error) {
 
 	if rw {
 		// 如果需要续期，则使用读写事务。
-		if err = db.Update(func(txn *badger.Txn) error {
+		if err = s.db.Update(func(txn *badger.Txn) error {
 			// 先获取值。
 			if err = getFunc(txn); err != nil {
 				return err
 			}
-			// 如果键存在，则更新其 TTL。
+			// 如果键存在，则更新其 TTL。迁移过的值按配置以当前版本写回。
+			if exists && migrated && s.opts.MigrateOnRead {
+				expiresAt := time.Now().Add(time.Duration(ttl[0]) * time.Millisecond).Unix()
+				return s.writeBack(txn, k, value, uint64(expiresAt))
+			}
 			if exists {
 				var item *badger.Item
 				if item, err = txn.Get(k); err != nil {
 					return err
 				}
 				if err = item.Value(func(val []byte) (err error) {
-					entry := badger.NewEntry(k, val).WithTTL(time.Duration(ttl[0]) * time.Millisecond)
+					entry := badger.NewEntry(k, val).WithMeta(item.UserMeta()).WithTTL(time.Duration(ttl[0]) * time.Millisecond)
 					if err = txn.SetEntry(entry); err != nil {
 						return
 					}
@@ -165,32 +130,35 @@ func Get[K, V any](key K, ttl ...int64) (V, bool, error) {
 			}
 			return nil
 		}); err != nil {
-			return value, exists, err
+			return exists, err
 		}
 	} else {
 		// 如果不需要续期，则使用只读事务。
-		if err = db.View(func(txn *badger.Txn) error {
+		if err = s.db.View(func(txn *badger.Txn) error {
 			return getFunc(txn)
 		}); err != nil {
-			return value, exists, err
+			return exists, err
+		}
+		// 迁移过的值按配置以当前版本写回。
+		if migrated && s.opts.MigrateOnRead {
+			err = s.writeBackIf(k, version, value)
 		}
 	}
 
-	return value, exists, err
+	return exists, err
 }
 
 // Del 从数据库中删除一个键。
-// K 是泛型参数，代表任意类型的键。
 // key: 要删除的键。
-func Del[K any](key K) error {
+func (s *Store) Del(key any) error {
 	// 序列化键。
-	k, err := serialize[K](key)
+	k, err := serializeKey(key)
 	if err != nil {
 		return err
 	}
 
 	// 执行数据库更新操作以删除键。
-	if err = db.Update(func(txn *badger.Txn) (err error) {
+	if err = s.db.Update(func(txn *badger.Txn) (err error) {
 		if err = txn.Delete(k); err != nil {
 			return err
 		}
@@ -202,6 +170,93 @@ func Del[K any](key K) error {
 }
 
 // Exists 检查数据库中是否存在一个键，并可选择性地更新其生存时间 (TTL)。
+// 它只读取键的元数据，不读取也不解码值。
+// key: 要检查的键。
+// ttl: 可选参数，生命周期，单位毫秒。如果提供，将更新键的 TTL。
+// 返回值:
+// bool: 表示键是否存在。
+// error: 操作中发生的任何错误。
+func (s *Store) Exists(key any, ttl ...int64) (bool, error) {
+	// 如果需要续期，则交给 Touch 处理。
+	if len(ttl) > 0 {
+		return s.Touch(key, ttl[0])
+	}
+
+	// 序列化键。
+	k, err := serializeKey(key)
+	if err != nil {
+		return false, err
+	}
+
+	exists := true
+	if err = s.db.View(func(txn *badger.Txn) error {
+		if _, err = txn.Get(k); err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				exists = false
+				return nil
+			}
+			return err
+		}
+		return nil
+	}); err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+// Touch 将键的生存时间续期为 ttl，不解码值。
+// key: 键。
+// ttl: 生命周期，单位毫秒。
+// 返回值:
+// bool: 表示键是否存在，不存在时不做任何操作。
+// error: 操作中发生的任何错误。
+func (s *Store) Touch(key any, ttl int64) (bool, error) {
+	return s.Expire(key, time.Duration(ttl)*time.Millisecond)
+}
+
+// Set 将键值对存入默认 Store，可选择性地设置生存时间 (TTL)。
+// K, V 是泛型参数，代表任意类型的键和值。
+// key: 键。
+// value: 值。
+// ttl: 可选参数，生命周期，单位毫秒。
+func Set[K, V any](key K, value V, ttl ...int64) error {
+	s, err := defaultStore()
+	if err != nil {
+		return err
+	}
+	return s.Set(key, value, ttl...)
+}
+
+// Get 从默认 Store 中获取一个值，并可选择性地更新其生存时间 (TTL)。
+// K, V 是泛型参数，代表任意类型的键和值。
+// key: 键。
+// ttl: 可选参数，生命周期，单位毫秒。如果提供，将更新键的 TTL。
+// 返回值:
+// V: 获取到的值。
+// bool: 表示键是否存在。
+// error: 操作中发生的任何错误。
+func Get[K, V any](key K, ttl ...int64) (V, bool, error) {
+	var value V
+	s, err := defaultStore()
+	if err != nil {
+		return value, false, err
+	}
+	exists, err := s.Get(key, &value, ttl...)
+	return value, exists, err
+}
+
+// Del 从默认 Store 中删除一个键。
+// K 是泛型参数，代表任意类型的键。
+// key: 要删除的键。
+func Del[K any](key K) error {
+	s, err := defaultStore()
+	if err != nil {
+		return err
+	}
+	return s.Del(key)
+}
+
+// Exists 检查默认 Store 中是否存在一个键，并可选择性地更新其生存时间 (TTL)。
 // K 是泛型参数，代表任意类型的键。
 // key: 要检查的键。
 // ttl: 可选参数，生命周期，单位毫秒。如果提供，将更新键的 TTL。
@@ -209,20 +264,35 @@ func Del[K any](key K) error {
 // bool: 表示键是否存在。
 // error: 操作中发生的任何错误。
 func Exists[K any](key K, ttl ...int64) (bool, error) {
-	// 通过调用 Get 函数并忽略值来实现。
-	_, exists, err := Get[K, any](key, ttl...)
+	s, err := defaultStore()
 	if err != nil {
 		return false, err
 	}
-	return exists, nil
+	return s.Exists(key, ttl...)
+}
+
+// Touch 将默认 Store 中键的生存时间续期为 ttl，不解码值。
+// K 是泛型参数，代表任意类型的键。
+// key: 键。
+// ttl: 生命周期，单位毫秒。
+// 返回值:
+// bool: 表示键是否存在，不存在时不做任何操作。
+// error: 操作中发生的任何错误。
+func Touch[K any](key K, ttl int64) (bool, error) {
+	s, err := defaultStore()
+	if err != nil {
+		return false, err
+	}
+	return s.Touch(key, ttl)
 }
 
-// Drop 清空整个数据库。
+// Drop 清空默认 Store。
 func Drop() error {
-	if err := db.DropAll(); err != nil {
+	s, err := defaultStore()
+	if err != nil {
 		return err
 	}
-	return nil
+	return s.Drop()
 }
 
 // HashKey 将字符串散列为 []byte，用作数据库的键。
@@ -234,19 +304,26 @@ func HashKey(text string) []byte {
 }
 
 // IntKey 将 int64 转换为 []byte，用作数据库的键。
+// 编码是大端补码，负数排在正数之后；需要按数值排序时使用 OrderedIntKey。
 func IntKey(n int64) []byte {
 	buf := make([]byte, 8)
 	binary.BigEndian.PutUint64(buf, uint64(n))
 	return buf
 }
 
-// Close 函数用于关闭数据库连接。
-// 在程序退出前调用此函数是很好的做法，以确保所有数据都被正确写入磁盘。
-func Close() {
-	if err := db.Close(); err != nil {
-		pcolor.PrintError(prefix, err)
-		return
-	}
-	pcolor.PrintSucc(prefix, "conn closed")
-	return
+// OrderedIntKey 将 int64 转换为 []byte，用作数据库的键。
+// 符号位被翻转，因此负数排在正数之前，字节序与数值大小一致，与 OrderedInt 键的编码相同。
+func OrderedIntKey(n int64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, uint64(n)^(1<<63))
+	return buf
+}
+
+// OrderedFloatKey 将 float64 转换为 []byte，用作数据库的键。
+// 编码后的字节序与数值大小一致，与 OrderedFloat 键的编码相同。
+func OrderedFloatKey(f float64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, float64Bits(f))
+	orderBytes(buf, reflect.Float64, true)
+	return buf
 }
@@ -97,3 +97,28 @@ func TestNilValue(t *testing.T) {
 		t.Errorf("Get() with nil value gotValue = %v, want nil", gotValue)
 	}
 }
+
+func TestExists_Touch(t *testing.T) {
+	key := "test_key_touch"
+	// A struct value cannot be decoded as any, so Exists must not decode it.
+	if err := Set(key, struct{ A int }{A: 1}, 1000); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	exists, err := Touch(key, 60000)
+	if err != nil || !exists {
+		t.Fatalf("Touch() = %v, %v, want true", exists, err)
+	}
+	ttl, _, err := TTL(key)
+	if err != nil || ttl < 50*time.Second {
+		t.Errorf("TTL() after Touch = %v, %v, want about 60s", ttl, err)
+	}
+
+	exists, err = Exists(key, 1000)
+	if err != nil || !exists {
+		t.Fatalf("Exists() with ttl = %v, %v, want true", exists, err)
+	}
+	if exists, err = Touch("test_key_touch_missing", 1000); err != nil || exists {
+		t.Errorf("Touch() on missing key = %v, %v, want false", exists, err)
+	}
+}
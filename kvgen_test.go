@@ -0,0 +1,214 @@
+// Code generated by kvgen. DO NOT EDIT.
+
+package kv
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"time"
+)
+
+// MarshalKV 实现 kv.Marshaler。
+func (x genProfile) MarshalKV() ([]byte, error) {
+	var b []byte
+	b = kvgenAppendBytes(b, []byte(x.Bio))
+	b = binary.AppendUvarint(b, uint64(len(x.Tags)))
+	for _, e1 := range x.Tags {
+		b = kvgenAppendBytes(b, []byte(e1))
+	}
+	return b, nil
+}
+
+// UnmarshalKV 实现 kv.Unmarshaler。
+func (x *genProfile) UnmarshalKV(data []byte) error {
+	r := &kvgenReader{data: data}
+	x.Bio = string(r.bytes())
+	if n := r.count(); n > 0 {
+		x.Tags = make([]string, n)
+		for i1 := range x.Tags {
+			x.Tags[i1] = string(r.bytes())
+		}
+	} else {
+		x.Tags = nil
+	}
+	return r.done()
+}
+
+// MarshalKV 实现 kv.Marshaler。
+func (x genUser) MarshalKV() ([]byte, error) {
+	var b []byte
+	b = binary.BigEndian.AppendUint64(b, uint64(x.ID))
+	b = kvgenAppendBytes(b, []byte(x.Name))
+	b = kvgenAppendBool(b, x.Active)
+	b = binary.BigEndian.AppendUint64(b, math.Float64bits(x.Score))
+	b = binary.BigEndian.AppendUint16(b, uint16(x.Rank))
+	b = kvgenAppendBytes(b, []byte(x.Avatar))
+	{
+		v, err := x.Created.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		b = kvgenAppendBytes(b, v)
+	}
+	b = binary.BigEndian.AppendUint64(b, uint64(x.Timeout))
+	b = binary.AppendUvarint(b, uint64(len(x.Scores)))
+	for _, e1 := range x.Scores {
+		b = binary.BigEndian.AppendUint32(b, math.Float32bits(e1))
+	}
+	{
+		v, err := x.Profile.MarshalKV()
+		if err != nil {
+			return nil, err
+		}
+		b = kvgenAppendBytes(b, v)
+	}
+	b = binary.AppendUvarint(b, uint64(len(x.Friends)))
+	for _, e1 := range x.Friends {
+		{
+			v, err := e1.MarshalKV()
+			if err != nil {
+				return nil, err
+			}
+			b = kvgenAppendBytes(b, v)
+		}
+	}
+	return b, nil
+}
+
+// UnmarshalKV 实现 kv.Unmarshaler。
+func (x *genUser) UnmarshalKV(data []byte) error {
+	r := &kvgenReader{data: data}
+	x.ID = int64(r.u64())
+	x.Name = string(r.bytes())
+	x.Active = r.u8() != 0
+	x.Score = math.Float64frombits(r.u64())
+	x.Rank = int16(r.u16())
+	x.Avatar = r.copyBytes()
+	if v := r.bytes(); r.err == nil {
+		if err := x.Created.UnmarshalBinary(v); err != nil {
+			return err
+		}
+	}
+	x.Timeout = time.Duration(r.u64())
+	if n := r.count(); n > 0 {
+		x.Scores = make([]float32, n)
+		for i1 := range x.Scores {
+			x.Scores[i1] = math.Float32frombits(r.u32())
+		}
+	} else {
+		x.Scores = nil
+	}
+	if v := r.bytes(); r.err == nil {
+		if err := x.Profile.UnmarshalKV(v); err != nil {
+			return err
+		}
+	}
+	if n := r.count(); n > 0 {
+		x.Friends = make([]genProfile, n)
+		for i1 := range x.Friends {
+			if v := r.bytes(); r.err == nil {
+				if err := x.Friends[i1].UnmarshalKV(v); err != nil {
+					return err
+				}
+			}
+		}
+	} else {
+		x.Friends = nil
+	}
+	return r.done()
+}
+
+func kvgenAppendBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, 1)
+	}
+	return append(b, 0)
+}
+
+func kvgenAppendBytes(b, v []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// kvgenReader 按顺序读取编码后的字段，遇到错误后的读取都返回零值。
+type kvgenReader struct {
+	data []byte
+	err  error
+}
+
+func (r *kvgenReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.data) < n {
+		r.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *kvgenReader) u8() uint8 {
+	if b := r.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *kvgenReader) u16() uint16 {
+	if b := r.next(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *kvgenReader) u32() uint32 {
+	if b := r.next(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *kvgenReader) u64() uint64 {
+	if b := r.next(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+// count 读取切片长度。每个元素至少占一个字节，因此长度不能超过剩余的数据。
+func (r *kvgenReader) count() int {
+	if r.err != nil {
+		return 0
+	}
+	n, size := binary.Uvarint(r.data)
+	if size <= 0 || n > uint64(len(r.data)-size) {
+		r.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	r.data = r.data[size:]
+	return int(n)
+}
+
+func (r *kvgenReader) bytes() []byte {
+	n := r.count()
+	return r.next(n)
+}
+
+func (r *kvgenReader) copyBytes() []byte {
+	b := r.bytes()
+	if len(b) == 0 {
+		return nil
+	}
+	return append([]byte(nil), b...)
+}
+
+func (r *kvgenReader) done() error {
+	if r.err == nil && len(r.data) > 0 {
+		return errors.New("kvgen: unexpected trailing data")
+	}
+	return r.err
+}
@@ -0,0 +1,8 @@
+//go:build windows || plan9 || js || wasip1 || aix
+
+package kv
+
+// lockDir 在不支持 flock 的平台上不加锁，只依靠 Badger 的 LOCK 文件判断目录是否正在使用。
+func lockDir(string) (func(), error) {
+	return func() {}, nil
+}
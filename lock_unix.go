@@ -0,0 +1,24 @@
+//go:build !windows && !plan9 && !js && !wasip1 && !aix
+
+package kv
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// lockDir 以排他方式锁定数据库目录 path。Badger 打开目录时对目录加同样的 flock（只读打开时为共享锁），
+// 因此目录被任何进程打开时都会失败。返回的函数释放锁。
+func lockDir(path string) (func(), error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if err = unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB); err != nil {
+		_ = f.Close()
+		return nil, errors.Wrapf(ErrDatabaseInUse, "lock %s: %v", path, err)
+	}
+	return func() { _ = f.Close() }, nil
+}
@@ -0,0 +1,32 @@
+package kv
+
+import "reflect"
+
+// Marshaler 由不依赖反射的序列化器实现，通常由 cmd/kvgen 生成。
+// serialize 和 HybridCodec 在使用反射之前优先调用 MarshalKV。
+type Marshaler interface {
+	// MarshalKV 将值编码为字节切片。
+	MarshalKV() ([]byte, error)
+}
+
+// Unmarshaler 是 Marshaler 对应的解码接口，通常由 cmd/kvgen 生成。
+type Unmarshaler interface {
+	// UnmarshalKV 将 MarshalKV 生成的数据解码到接收者。
+	UnmarshalKV(data []byte) error
+}
+
+var unmarshalerType = reflect.TypeFor[Unmarshaler]()
+
+// marshalKV 在 data 实现了 Marshaler 时调用 MarshalKV。nil 指针编码为 nil。
+// 返回的 bool 表示 data 是否实现了 Marshaler。
+func marshalKV(data any) ([]byte, bool, error) {
+	m, ok := data.(Marshaler)
+	if !ok {
+		return nil, false, nil
+	}
+	if v := reflect.ValueOf(data); v.Kind() == reflect.Ptr && v.IsNil() {
+		return nil, true, nil
+	}
+	b, err := m.MarshalKV()
+	return b, true, err
+}
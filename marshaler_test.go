@@ -0,0 +1,122 @@
+package kv
+
+import (
+	"bytes"
+	"encoding/gob"
+	"reflect"
+	"testing"
+	"time"
+)
+
+//go:generate go run ./cmd/kvgen -tests -output kvgen_test.go
+
+//kvgen:marshal
+type genProfile struct {
+	Bio  string
+	Tags []string
+}
+
+//kvgen:marshal
+type genUser struct {
+	ID      int64
+	Name    string
+	Active  bool
+	Score   float64
+	Rank    int16
+	Avatar  []byte
+	Created time.Time
+	Timeout time.Duration
+	Scores  []float32
+	Profile genProfile
+	Friends []genProfile
+	Cache   string `kv:"-"`
+}
+
+var testUser = genUser{
+	ID:      42,
+	Name:    "alice",
+	Active:  true,
+	Score:   99.5,
+	Rank:    -3,
+	Avatar:  []byte{0xFF, 0x00},
+	Created: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	Timeout: 3 * time.Second,
+	Scores:  []float32{1.5, 2.5},
+	Profile: genProfile{Bio: "hi", Tags: []string{"a", "b"}},
+	Friends: []genProfile{{Bio: "bob"}},
+}
+
+func TestMarshalKV_RoundTrip(t *testing.T) {
+	data, err := serialize(testUser)
+	if err != nil {
+		t.Fatalf("serialize() error = %v", err)
+	}
+	want, _ := testUser.MarshalKV()
+	if !bytes.Equal(data, want) {
+		t.Error("serialize() should use MarshalKV")
+	}
+
+	got, err := deserialize[genUser](data)
+	if err != nil {
+		t.Fatalf("deserialize() error = %v", err)
+	}
+	expected := testUser
+	expected.Cache = ""
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %+v, want %+v", got, expected)
+	}
+
+	ptr, err := deserialize[*genUser](data)
+	if err != nil {
+		t.Fatalf("deserialize() error = %v", err)
+	}
+	if ptr.Name != "alice" {
+		t.Errorf("got %q, want alice", ptr.Name)
+	}
+}
+
+func TestMarshalKV_Truncated(t *testing.T) {
+	data, _ := testUser.MarshalKV()
+	var u genUser
+	if err := u.UnmarshalKV(data[:len(data)-1]); err == nil {
+		t.Error("UnmarshalKV() on truncated data should fail")
+	}
+	if err := u.UnmarshalKV(append(data, 0)); err == nil {
+		t.Error("UnmarshalKV() with trailing data should fail")
+	}
+}
+
+func TestStore_Marshaler(t *testing.T) {
+	s, err := Open(Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	if err := s.Set("user", testUser); err != nil {
+		t.Fatal(err)
+	}
+	var got genUser
+	if _, err := s.Get("user", &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != 42 || !got.Created.Equal(testUser.Created) {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func BenchmarkMarshalKV(b *testing.B) {
+	for b.Loop() {
+		data, _ := serialize(testUser)
+		_, _ = deserialize[genUser](data)
+	}
+}
+
+func BenchmarkGob_Struct(b *testing.B) {
+	for b.Loop() {
+		var buf bytes.Buffer
+		_ = gob.NewEncoder(&buf).Encode(testUser)
+		var out genUser
+		_ = gob.NewDecoder(&buf).Decode(&out)
+	}
+}
@@ -0,0 +1,327 @@
+package kv
+
+import (
+	"encoding/binary"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+/*
+native 为常见的非标量类型提供不依赖 gob 的紧凑编码，由 serialize 和 deserializeValue 优先使用：
+  - []byte 和 [N]byte 原样存储。
+  - 定长数值（整数、浮点数、复数、bool）的切片和数组，按元素依次写入大端编码，不带长度前缀。
+  - []string 和 [N]string，每个元素写入 uvarint 长度和内容。
+  - 键为 string、值为定长数值或 string 的 map，按键排序后依次写入键和值，保证编码结果确定。
+  - time.Time 使用 MarshalBinary。time.Duration 是 int64，本来就按 8 字节编码。
+这些类型在旧版本中使用 gob 编码，而 []byte 等原生格式无法与 gob 数据区分，因此格式由 UserMeta 标记：
+HybridCodec 写入的值在 UserMeta 中设置 metaNative 位，没有这个位的值是旧版本写入的，
+按 serializeLegacy 的格式（非基础类型一律使用 gob）解码。键不受影响，始终使用旧版本的格式。
+*/
+
+// metaNative 是 UserMeta 中表示值由当前版本的 HybridCodec 编码的位。
+const metaNative byte = 1 << 5
+
+var byteType = reflect.TypeOf(byte(0))
+
+// serializeNative 尝试以原生格式编码 v。
+// 返回值:
+// []byte: 编码结果。
+// bool: v 的类型是否支持原生编码，不支持时调用者应回退到 gob。
+// error: 编码过程中发生的任何错误。
+func serializeNative(v reflect.Value) ([]byte, bool, error) {
+	t := v.Type()
+	if t == timeType {
+		data, err := v.Interface().(time.Time).MarshalBinary()
+		return data, true, err
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		elem := t.Elem()
+		if elem.Kind() == reflect.Uint8 {
+			// 字节切片和字节数组原样存储。
+			if t.Kind() == reflect.Slice {
+				return append([]byte{}, v.Bytes()...), true, nil
+			}
+			// 元素可能是以 byte 为底层类型的命名类型，reflect.Copy 要求元素类型相同，因此逐个复制。
+			buf := make([]byte, v.Len())
+			for i := range buf {
+				buf[i] = byte(v.Index(i).Uint())
+			}
+			return buf, true, nil
+		}
+		if size := fixedSize(elem.Kind()); size > 0 {
+			buf := make([]byte, 0, v.Len()*size)
+			for i := 0; i < v.Len(); i++ {
+				buf = appendFixed(buf, v.Index(i))
+			}
+			return buf, true, nil
+		}
+		if elem.Kind() == reflect.String {
+			buf := []byte{}
+			for i := 0; i < v.Len(); i++ {
+				buf = appendString(buf, v.Index(i).String())
+			}
+			return buf, true, nil
+		}
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String || !isNativeMapValue(t.Elem().Kind()) {
+			return nil, false, nil
+		}
+		keys := v.MapKeys()
+		slices.SortFunc(keys, func(a, b reflect.Value) int {
+			if a.String() < b.String() {
+				return -1
+			}
+			if a.String() > b.String() {
+				return 1
+			}
+			return 0
+		})
+		buf := []byte{}
+		for _, key := range keys {
+			buf = appendString(buf, key.String())
+			value := v.MapIndex(key)
+			if value.Kind() == reflect.String {
+				buf = appendString(buf, value.String())
+			} else {
+				buf = appendFixed(buf, value)
+			}
+		}
+		return buf, true, nil
+	}
+	return nil, false, nil
+}
+
+// deserializeNative 尝试以原生格式将 data 解码为类型 t 的值。
+// 返回值:
+// reflect.Value: 解码结果。
+// bool: t 是否支持原生编码。
+// error: data 不符合原生格式时返回错误。
+func deserializeNative(data []byte, t reflect.Type) (reflect.Value, bool, error) {
+	if t == timeType {
+		var tm time.Time
+		if err := tm.UnmarshalBinary(data); err != nil {
+			return reflect.Value{}, true, errors.Wrapf(errInvalidLength, "invalid time data: %v", err)
+		}
+		return reflect.ValueOf(tm), true, nil
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		elem := t.Elem()
+		var n int
+		var read func(v reflect.Value) error
+		switch size := fixedSize(elem.Kind()); {
+		case elem.Kind() == reflect.Uint8:
+			n = len(data)
+			read = func(v reflect.Value) error {
+				if elem == byteType {
+					reflect.Copy(v, reflect.ValueOf(data))
+					return nil
+				}
+				for i, b := range data {
+					v.Index(i).SetUint(uint64(b))
+				}
+				return nil
+			}
+		case size > 0:
+			if len(data)%size != 0 {
+				return reflect.Value{}, true, errors.Wrapf(errInvalidLength, "%d bytes for %s", len(data), t)
+			}
+			n = len(data) / size
+			read = func(v reflect.Value) error {
+				for i := 0; i < n; i++ {
+					setFixed(v.Index(i), data[i*size:])
+				}
+				return nil
+			}
+		case elem.Kind() == reflect.String:
+			strs, err := readStrings(data)
+			if err != nil {
+				return reflect.Value{}, true, err
+			}
+			n = len(strs)
+			read = func(v reflect.Value) error {
+				for i, s := range strs {
+					v.Index(i).SetString(s)
+				}
+				return nil
+			}
+		default:
+			return reflect.Value{}, false, nil
+		}
+
+		var value reflect.Value
+		if t.Kind() == reflect.Slice {
+			value = reflect.MakeSlice(t, n, n)
+		} else {
+			if n != t.Len() {
+				return reflect.Value{}, true, errors.Wrapf(errInvalidLength, "%d bytes for %s", len(data), t)
+			}
+			value = reflect.New(t).Elem()
+		}
+		err := read(value)
+		return value, true, err
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String || !isNativeMapValue(t.Elem().Kind()) {
+			return reflect.Value{}, false, nil
+		}
+		value := reflect.MakeMap(t)
+		elem := t.Elem()
+		for len(data) > 0 {
+			key, rest, err := readString(data)
+			if err != nil {
+				return reflect.Value{}, true, err
+			}
+			data = rest
+			ev := reflect.New(elem).Elem()
+			if elem.Kind() == reflect.String {
+				var s string
+				if s, data, err = readString(data); err != nil {
+					return reflect.Value{}, true, err
+				}
+				ev.SetString(s)
+			} else {
+				size := fixedSize(elem.Kind())
+				if len(data) < size {
+					return reflect.Value{}, true, errors.Wrapf(errInvalidLength, "insufficient data for %s", t)
+				}
+				setFixed(ev, data)
+				data = data[size:]
+			}
+			value.SetMapIndex(reflect.ValueOf(key).Convert(t.Key()), ev)
+		}
+		return value, true, nil
+	}
+	return reflect.Value{}, false, nil
+}
+
+// fixedSize 返回定长数值类型编码后的字节数，非定长类型返回 0。
+func fixedSize(k reflect.Kind) int {
+	switch k {
+	case reflect.Bool, reflect.Int8, reflect.Uint8:
+		return 1
+	case reflect.Int16, reflect.Uint16:
+		return 2
+	case reflect.Int32, reflect.Uint32, reflect.Float32:
+		return 4
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64, reflect.Float64, reflect.Complex64:
+		return 8
+	case reflect.Complex128:
+		return 16
+	}
+	return 0
+}
+
+// isNativeMapValue 判断 map 的值类型是否支持原生编码。
+func isNativeMapValue(k reflect.Kind) bool {
+	return k == reflect.String || fixedSize(k) > 0
+}
+
+// appendFixed 以与 serialize 相同的大端格式追加一个定长数值。
+func appendFixed(buf []byte, v reflect.Value) []byte {
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return append(buf, 1)
+		}
+		return append(buf, 0)
+	case reflect.Int8:
+		return append(buf, byte(v.Int()))
+	case reflect.Uint8:
+		return append(buf, byte(v.Uint()))
+	case reflect.Int16:
+		return binary.BigEndian.AppendUint16(buf, uint16(v.Int()))
+	case reflect.Uint16:
+		return binary.BigEndian.AppendUint16(buf, uint16(v.Uint()))
+	case reflect.Int32:
+		return binary.BigEndian.AppendUint32(buf, uint32(v.Int()))
+	case reflect.Uint32:
+		return binary.BigEndian.AppendUint32(buf, uint32(v.Uint()))
+	case reflect.Int, reflect.Int64:
+		return binary.BigEndian.AppendUint64(buf, uint64(v.Int()))
+	case reflect.Uint, reflect.Uint64:
+		return binary.BigEndian.AppendUint64(buf, v.Uint())
+	case reflect.Float32:
+		return binary.BigEndian.AppendUint32(buf, float32Bits(float32(v.Float())))
+	case reflect.Float64:
+		return binary.BigEndian.AppendUint64(buf, float64Bits(v.Float()))
+	case reflect.Complex64:
+		c := complex64(v.Complex())
+		buf = binary.BigEndian.AppendUint32(buf, float32Bits(real(c)))
+		return binary.BigEndian.AppendUint32(buf, float32Bits(imag(c)))
+	case reflect.Complex128:
+		c := v.Complex()
+		buf = binary.BigEndian.AppendUint64(buf, float64Bits(real(c)))
+		return binary.BigEndian.AppendUint64(buf, float64Bits(imag(c)))
+	}
+	return buf
+}
+
+// setFixed 从 data 的开头读取一个定长数值并写入 v，调用者需保证长度足够。
+func setFixed(v reflect.Value, data []byte) {
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(data[0] != 0)
+	case reflect.Int8:
+		v.SetInt(int64(int8(data[0])))
+	case reflect.Uint8:
+		v.SetUint(uint64(data[0]))
+	case reflect.Int16:
+		v.SetInt(int64(int16(binary.BigEndian.Uint16(data))))
+	case reflect.Uint16:
+		v.SetUint(uint64(binary.BigEndian.Uint16(data)))
+	case reflect.Int32:
+		v.SetInt(int64(int32(binary.BigEndian.Uint32(data))))
+	case reflect.Uint32:
+		v.SetUint(uint64(binary.BigEndian.Uint32(data)))
+	case reflect.Int, reflect.Int64:
+		v.SetInt(int64(binary.BigEndian.Uint64(data)))
+	case reflect.Uint, reflect.Uint64:
+		v.SetUint(binary.BigEndian.Uint64(data))
+	case reflect.Float32:
+		v.SetFloat(float64(float32FromBits(binary.BigEndian.Uint32(data))))
+	case reflect.Float64:
+		v.SetFloat(float64FromBits(binary.BigEndian.Uint64(data)))
+	case reflect.Complex64:
+		re := float32FromBits(binary.BigEndian.Uint32(data[0:4]))
+		im := float32FromBits(binary.BigEndian.Uint32(data[4:8]))
+		v.SetComplex(complex128(complex(re, im)))
+	case reflect.Complex128:
+		re := float64FromBits(binary.BigEndian.Uint64(data[0:8]))
+		im := float64FromBits(binary.BigEndian.Uint64(data[8:16]))
+		v.SetComplex(complex(re, im))
+	}
+}
+
+// appendString 追加 uvarint 长度前缀和字符串内容。
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+// readString 读取一个带 uvarint 长度前缀的字符串，并返回剩余的数据。
+func readString(data []byte) (string, []byte, error) {
+	n, size := binary.Uvarint(data)
+	if size <= 0 || uint64(len(data)-size) < n {
+		return "", nil, errors.Wrap(errInvalidLength, "invalid string data")
+	}
+	data = data[size:]
+	return string(data[:n]), data[n:], nil
+}
+
+// readStrings 读取连续的带长度前缀的字符串，直到数据结束。
+func readStrings(data []byte) ([]string, error) {
+	var strs []string
+	for len(data) > 0 {
+		s, rest, err := readString(data)
+		if err != nil {
+			return nil, err
+		}
+		strs = append(strs, s)
+		data = rest
+	}
+	return strs, nil
+}
@@ -0,0 +1,242 @@
+package kv
+
+import (
+	"bytes"
+	"encoding/gob"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type userIDs []int64
+
+type myByte byte
+
+func TestSerialize_NativeRoundTrip(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name  string
+		value any
+	}{
+		{"bytes", []byte("hello")},
+		{"byte array", [4]byte{1, 2, 3, 4}},
+		{"named byte slice", []myByte{1, 2, 3}},
+		{"named byte array", [3]myByte{1, 2, 3}},
+		{"int64 slice", []int64{-1, 0, 1 << 40}},
+		{"named slice", userIDs{7, 8, 9}},
+		{"float64 slice", []float64{-1.5, 0, 3.25}},
+		{"uint16 array", [3]uint16{1, 2, 65535}},
+		{"bool slice", []bool{true, false, true}},
+		{"complex slice", []complex128{1 + 2i}},
+		{"string slice", []string{"a", "", "hello world"}},
+		{"string map", map[string]string{"a": "x", "b": ""}},
+		{"int map", map[string]int{"a": 1, "b": -2}},
+		{"float map", map[string]float64{"pi": 3.14}},
+		{"time", now},
+		{"duration", 90 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := serialize(tt.value)
+			if err != nil {
+				t.Fatalf("serialize() error = %v", err)
+			}
+			got, err := deserializeType(data, reflect.TypeOf(tt.value), true)
+			if err != nil {
+				t.Fatalf("deserializeType() error = %v", err)
+			}
+			if tm, ok := tt.value.(time.Time); ok {
+				if !got.Interface().(time.Time).Equal(tm) {
+					t.Errorf("got %v, want %v", got, tm)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got.Interface(), tt.value) {
+				t.Errorf("got %#v, want %#v", got.Interface(), tt.value)
+			}
+		})
+	}
+}
+
+func TestSerialize_NativeFormat(t *testing.T) {
+	data, err := serialize([]byte{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, []byte{1, 2, 3}) {
+		t.Errorf("[]byte should be stored raw, got %v", data)
+	}
+
+	data, err = serialize([]int32{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 8 {
+		t.Errorf("[]int32 of 2 elements should take 8 bytes, got %d", len(data))
+	}
+
+	// Map encoding must not depend on iteration order.
+	m := map[string]int{"a": 1, "b": 2, "c": 3, "d": 4}
+	first, _ := serialize(m)
+	for range 10 {
+		again, _ := serialize(m)
+		if !bytes.Equal(first, again) {
+			t.Fatal("map encoding is not deterministic")
+		}
+	}
+}
+
+// Values written before the native format carry no metaNative bit and are gob-encoded.
+func TestStore_LegacyGobValues(t *testing.T) {
+	s, err := Open(Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	now := time.Now()
+	for name, value := range map[string]any{
+		"bytes":   []byte("hello"),
+		"strings": []string{"a", "b"},
+		"map":     map[string]int{"a": 1},
+		"array":   [2]int64{1, 2},
+		"time":    now,
+	} {
+		var buf bytes.Buffer
+		if err = gob.NewEncoder(&buf).Encode(value); err != nil {
+			t.Fatal(err)
+		}
+		putRaw(t, s, name, buf.Bytes())
+		got := reflect.New(reflect.TypeOf(value))
+		if _, err = s.Get(name, got.Interface()); err != nil {
+			t.Fatalf("Get(%s) error = %v", name, err)
+		}
+		if tm, ok := value.(time.Time); ok {
+			if !got.Elem().Interface().(time.Time).Equal(tm) {
+				t.Errorf("Get(%s) = %v, want %v", name, got.Elem(), tm)
+			}
+		} else if !reflect.DeepEqual(got.Elem().Interface(), value) {
+			t.Errorf("Get(%s) = %#v, want %#v", name, got.Elem(), value)
+		}
+	}
+
+	// New writes are marked and use the native layout.
+	if err = s.Set("bytes", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	raw, _, err := s.GetBytes("bytes")
+	if err != nil || !bytes.Equal(raw, []byte("hello")) {
+		t.Errorf("GetBytes() = %q, %v, want hello", raw, err)
+	}
+}
+
+// Keys keep the encoding of older versions, e.g. []byte keys stay gob-encoded.
+func TestSerializeKey_LegacyBytes(t *testing.T) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(HashKey("a")); err != nil {
+		t.Fatal(err)
+	}
+	k, err := serializeKey(HashKey("a"))
+	if err != nil || !bytes.Equal(k, buf.Bytes()) {
+		t.Errorf("serializeKey(HashKey) = %x, %v, want %x", k, err, buf.Bytes())
+	}
+	back, err := deserializeKey[[]byte](k)
+	if err != nil || !bytes.Equal(back, HashKey("a")) {
+		t.Errorf("deserializeKey() = %x, %v", back, err)
+	}
+}
+
+func TestStore_NativeValues(t *testing.T) {
+	s, err := Open(Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	if err := s.Set("ids", []int64{1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	var ids []int64
+	if _, err := s.Get("ids", &ids); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ids, []int64{1, 2, 3}) {
+		t.Errorf("got %v, want [1 2 3]", ids)
+	}
+
+	// Byte containers with a named element type take the raw path without reflect.Copy.
+	if err := s.Set("named_array", [3]myByte{1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	var arr [3]myByte
+	if _, err := s.Get("named_array", &arr); err != nil || arr != [3]myByte{1, 2, 3} {
+		t.Errorf("Get() = %v, %v, want [1 2 3]", arr, err)
+	}
+	if err := s.Set("named_slice", []myByte{4, 5}); err != nil {
+		t.Fatal(err)
+	}
+	var slice []myByte
+	if _, err := s.Get("named_slice", &slice); err != nil || !reflect.DeepEqual(slice, []myByte{4, 5}) {
+		t.Errorf("Get() = %v, %v, want [4 5]", slice, err)
+	}
+}
+
+var benchInts = func() []int64 {
+	ints := make([]int64, 256)
+	for i := range ints {
+		ints[i] = int64(i * i)
+	}
+	return ints
+}()
+
+var benchMap = map[string]string{"name": "kv", "lang": "go", "store": "badger", "codec": "hybrid"}
+
+func BenchmarkSerialize_IntSlice(b *testing.B) {
+	for b.Loop() {
+		data, _ := serialize(benchInts)
+		_, _ = deserialize[[]int64](data)
+	}
+}
+
+func BenchmarkGob_IntSlice(b *testing.B) {
+	for b.Loop() {
+		var buf bytes.Buffer
+		_ = gob.NewEncoder(&buf).Encode(benchInts)
+		var out []int64
+		_ = gob.NewDecoder(&buf).Decode(&out)
+	}
+}
+
+func BenchmarkSerialize_StringMap(b *testing.B) {
+	for b.Loop() {
+		data, _ := serialize(benchMap)
+		_, _ = deserialize[map[string]string](data)
+	}
+}
+
+func BenchmarkGob_StringMap(b *testing.B) {
+	for b.Loop() {
+		var buf bytes.Buffer
+		_ = gob.NewEncoder(&buf).Encode(benchMap)
+		var out map[string]string
+		_ = gob.NewDecoder(&buf).Decode(&out)
+	}
+}
+
+func BenchmarkSerialize_Time(b *testing.B) {
+	now := time.Now()
+	for b.Loop() {
+		data, _ := serialize(now)
+		_, _ = deserialize[time.Time](data)
+	}
+}
+
+func BenchmarkGob_Time(b *testing.B) {
+	now := time.Now()
+	for b.Loop() {
+		var buf bytes.Buffer
+		_ = gob.NewEncoder(&buf).Encode(now)
+		var out time.Time
+		_ = gob.NewDecoder(&buf).Decode(&out)
+	}
+}
@@ -0,0 +1,132 @@
+package kv
+
+import (
+	"bytes"
+	"encoding/base64"
+	"iter"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/pkg/errors"
+)
+
+// RangeOptions 控制 Range 的迭代行为。
+type RangeOptions struct {
+	ScanOptions
+	// StartExclusive 为 true 时不包含 start 本身，默认包含。
+	StartExclusive bool
+	// EndInclusive 为 true 时包含 end 本身，默认不包含。
+	EndInclusive bool
+	// Cursor 是上一页返回的游标，非空时从上一页的最后一个键之后继续。
+	Cursor string
+}
+
+// cursorVersion 是游标格式的版本号，写在游标的第一个字节。
+const cursorVersion = 1
+
+// ErrInvalidCursor 表示游标无法解析，或与本次迭代的方向不一致。
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Range 遍历默认 Store 中键位于 [start, end) 之间的键值对，边界是否包含由 opts 控制。
+// 过期的条目总是被跳过。范围按编码后的键的字节序比较，有符号数值的键应使用 OrderedInt 或 OrderedFloat。
+// K, V 是泛型参数，代表键和值的类型。
+// start: 范围的下界，nil 指针表示不限制。
+// end: 范围的上界，nil 指针表示不限制。
+// opts: 迭代选项。
+// 返回值:
+// iter.Seq2[K, V]: 键值对迭代器，每次遍历都会开启一个新的只读事务。
+// func() (string, error): 遍历结束后调用，返回下一页的游标和遍历中发生的错误。
+// 如果范围内没有更多条目，游标为空字符串。
+func Range[K, V any](start, end K, opts RangeOptions) (iter.Seq2[K, V], func() (string, error)) {
+	s, err := defaultStore()
+	if err != nil {
+		return func(func(K, V) bool) {}, func() (string, error) { return "", err }
+	}
+	return RangeStore[K, V](s, start, end, opts)
+}
+
+// RangeStore 与 Range 相同，但作用于指定的 Store。
+func RangeStore[K, V any](s *Store, start, end K, opts RangeOptions) (iter.Seq2[K, V], func() (string, error)) {
+	var (
+		cursor string
+		err    error
+	)
+	seq := func(yield func(K, V) bool) {
+		cursor = ""
+		var r keyRange
+		if r, err = newKeyRange(start, end, opts); err != nil {
+			return
+		}
+		var last []byte
+		var more bool
+		more, err = s.iterate(r, opts.ScanOptions, func(item *badger.Item) (bool, error) {
+			last = item.KeyCopy(last)
+			return yieldItem(s, item, opts.KeyOnly, yield)
+		})
+		if err == nil && more {
+			cursor = encodeCursor(last, opts.Reverse)
+		}
+	}
+	return seq, func() (string, error) { return cursor, err }
+}
+
+// newKeyRange 编码范围的边界，并根据游标调整迭代方向上的起点。
+func newKeyRange[K any](start, end K, opts RangeOptions) (keyRange, error) {
+	var r keyRange
+	var err error
+	if r.start, err = serializeKey(start); err != nil {
+		return r, err
+	}
+	if r.end, err = serializeKey(end); err != nil {
+		return r, err
+	}
+	r.startExclusive = opts.StartExclusive
+	r.endInclusive = opts.EndInclusive
+	if opts.Cursor == "" {
+		return r, nil
+	}
+
+	last, err := decodeCursor(opts.Cursor, opts.Reverse)
+	if err != nil {
+		return r, err
+	}
+	// 从游标的键之后继续：正序时收紧下界，逆序时收紧上界。
+	if opts.Reverse {
+		if r.end == nil || bytes.Compare(last, r.end) < 0 || (bytes.Equal(last, r.end) && !r.endInclusive) {
+			r.end, r.endInclusive = last, false
+		}
+	} else {
+		if r.start == nil || bytes.Compare(last, r.start) > 0 || (bytes.Equal(last, r.start) && r.startExclusive) {
+			r.start, r.startExclusive = last, true
+		}
+	}
+	return r, nil
+}
+
+// encodeCursor 将最后返回的键和迭代方向编码为不透明的游标。
+// 游标只依赖键的编码，因此在进程重启后仍然有效。
+func encodeCursor(key []byte, reverse bool) string {
+	buf := make([]byte, 0, len(key)+2)
+	buf = append(buf, cursorVersion)
+	if reverse {
+		buf = append(buf, 1)
+	} else {
+		buf = append(buf, 0)
+	}
+	buf = append(buf, key...)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+// decodeCursor 解析游标，并检查其迭代方向是否与 reverse 一致。
+func decodeCursor(cursor string, reverse bool) ([]byte, error) {
+	buf, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidCursor, err.Error())
+	}
+	if len(buf) < 2 || buf[0] != cursorVersion {
+		return nil, ErrInvalidCursor
+	}
+	if (buf[1] == 1) != reverse {
+		return nil, errors.Wrap(ErrInvalidCursor, "direction mismatch")
+	}
+	return buf[2:], nil
+}
@@ -0,0 +1,106 @@
+package kv
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRange(t *testing.T) {
+	s, err := Open(Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	for i := OrderedInt(-5); i <= 5; i++ {
+		if err = s.Set(i, i*10); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		opts RangeOptions
+		want []OrderedInt
+	}{
+		{"default bounds", RangeOptions{}, []OrderedInt{-2, -1, 0, 1}},
+		{"exclusive start", RangeOptions{StartExclusive: true}, []OrderedInt{-1, 0, 1}},
+		{"inclusive end", RangeOptions{EndInclusive: true}, []OrderedInt{-2, -1, 0, 1, 2}},
+		{"reverse", RangeOptions{ScanOptions: ScanOptions{Reverse: true}}, []OrderedInt{1, 0, -1, -2}},
+		{"reverse inclusive end", RangeOptions{ScanOptions: ScanOptions{Reverse: true}, EndInclusive: true, StartExclusive: true}, []OrderedInt{2, 1, 0, -1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seq, done := RangeStore[OrderedInt, OrderedInt](s, -2, 2, tt.opts)
+			var keys []OrderedInt
+			for k, v := range seq {
+				if v != k*10 {
+					t.Errorf("Range() value = %d, want %d", v, k*10)
+				}
+				keys = append(keys, k)
+			}
+			if _, err := done(); err != nil {
+				t.Fatalf("Range() error = %v", err)
+			}
+			if !reflect.DeepEqual(keys, tt.want) {
+				t.Errorf("Range() keys = %v, want %v", keys, tt.want)
+			}
+		})
+	}
+}
+
+func TestRange_Cursor(t *testing.T) {
+	s, err := Open(Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	for i := int64(0); i < 7; i++ {
+		if err = s.Set(i, i); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+	}
+
+	for _, reverse := range []bool{false, true} {
+		var keys []int64
+		cursor := ""
+		pages := 0
+		for {
+			opts := RangeOptions{ScanOptions: ScanOptions{Limit: 3, Reverse: reverse}, Cursor: cursor}
+			seq, done := RangeStore[int64, int64](s, 0, 100, opts)
+			for k := range seq {
+				keys = append(keys, k)
+			}
+			if cursor, err = done(); err != nil {
+				t.Fatalf("Range() error = %v", err)
+			}
+			pages++
+			if cursor == "" {
+				break
+			}
+		}
+		want := []int64{0, 1, 2, 3, 4, 5, 6}
+		if reverse {
+			want = []int64{6, 5, 4, 3, 2, 1, 0}
+		}
+		if !reflect.DeepEqual(keys, want) {
+			t.Errorf("Range() reverse=%v keys = %v, want %v", reverse, keys, want)
+		}
+		if pages != 3 {
+			t.Errorf("Range() reverse=%v pages = %d, want 3", reverse, pages)
+		}
+	}
+
+	seq, done := RangeStore[int64, int64](s, 0, 100, RangeOptions{ScanOptions: ScanOptions{Limit: 1}})
+	for range seq {
+	}
+	cursor, _ := done()
+	seq, done = RangeStore[int64, int64](s, 0, 100, RangeOptions{ScanOptions: ScanOptions{Reverse: true}, Cursor: cursor})
+	for range seq {
+	}
+	if _, err = done(); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Range() with mismatched cursor error = %v, want ErrInvalidCursor", err)
+	}
+}
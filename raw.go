@@ -0,0 +1,97 @@
+package kv
+
+import (
+	"github.com/dgraph-io/badger/v4"
+	"github.com/pkg/errors"
+)
+
+// ViewBytes 在只读事务中将默认 Store 中键的值的原始编码交给 fn，不复制也不解码。
+// 信封头部和 schema 版本字节会被去掉，fn 收到的是编解码器输出的字节，例如 []byte 值就是其本身。
+// raw 直接引用 Badger 的缓冲区，只在 fn 执行期间有效，fn 不能修改它，也不能在返回后继续持有它。
+// K 是泛型参数，代表任意类型的键。
+// key: 键。
+// fn: 处理原始编码的函数，键不存在时不会被调用。
+// 返回值:
+// bool: 表示键是否存在。
+// error: 操作中或 fn 返回的任何错误。
+func ViewBytes[K any](key K, fn func(raw []byte) error) (bool, error) {
+	s, err := defaultStore()
+	if err != nil {
+		return false, err
+	}
+	return s.ViewBytes(key, fn)
+}
+
+// GetBytes 返回默认 Store 中键的值的原始编码的副本，不解码。
+// 原始编码的含义同 ViewBytes。
+// K 是泛型参数，代表任意类型的键。
+// key: 键。
+// 返回值:
+// []byte: 原始编码的副本。
+// bool: 表示键是否存在。
+// error: 操作中发生的任何错误。
+func GetBytes[K any](key K) ([]byte, bool, error) {
+	s, err := defaultStore()
+	if err != nil {
+		return nil, false, err
+	}
+	return s.GetBytes(key)
+}
+
+// ViewBytes 将键的值的原始编码交给 fn，语义与包级别的 ViewBytes 相同。
+func (s *Store) ViewBytes(key any, fn func(raw []byte) error) (bool, error) {
+	k, err := serializeKey(key)
+	if err != nil {
+		return false, err
+	}
+	exists := true
+	err = s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(k)
+		if err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				exists = false
+				return nil
+			}
+			return err
+		}
+		if item.UserMeta()&metaStream != 0 {
+			return ErrStreamValue
+		}
+		return item.Value(func(val []byte) error {
+			raw, err := s.payload(k, val, item.UserMeta())
+			if err != nil {
+				return corrupt(item, err)
+			}
+			return fn(raw)
+		})
+	})
+	if err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+// GetBytes 返回键的值的原始编码的副本，语义与包级别的 GetBytes 相同。
+func (s *Store) GetBytes(key any) ([]byte, bool, error) {
+	var data []byte
+	exists, err := s.ViewBytes(key, func(raw []byte) error {
+		data = append([]byte(nil), raw...)
+		return nil
+	})
+	return data, exists, err
+}
+
+// payload 去掉键 k 的值 data 的信封头部和 schema 版本字节，返回编解码器输出的字节。
+func (s *Store) payload(k, data []byte, meta byte) ([]byte, error) {
+	data, err := s.rawValue(k, data, meta)
+	if err != nil {
+		return nil, err
+	}
+	if meta&metaVersioned != 0 {
+		if len(data) == 0 {
+			return nil, errors.New("missing schema version")
+		}
+		data = data[1:]
+	}
+	return data, nil
+}
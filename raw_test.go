@@ -0,0 +1,64 @@
+package kv
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestViewBytes(t *testing.T) {
+	for _, envelope := range []bool{false, true} {
+		s, err := Open(Options{Envelope: envelope})
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+
+		body := []byte("<html>cached</html>")
+		if err = s.Set("page", body); err != nil {
+			t.Fatal(err)
+		}
+
+		var seen []byte
+		exists, err := s.ViewBytes("page", func(raw []byte) error {
+			seen = append(seen, raw...)
+			return nil
+		})
+		if err != nil || !exists {
+			t.Fatalf("ViewBytes() = %v, %v", exists, err)
+		}
+		if !bytes.Equal(seen, body) {
+			t.Errorf("ViewBytes() raw = %q, want %q", seen, body)
+		}
+
+		got, exists, err := s.GetBytes("page")
+		if err != nil || !exists || !bytes.Equal(got, body) {
+			t.Errorf("GetBytes() = %q, %v, %v, want %q", got, exists, err, body)
+		}
+		_ = s.Close()
+	}
+}
+
+func TestViewBytes_Missing(t *testing.T) {
+	s, err := Open(Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	called := false
+	exists, err := s.ViewBytes("missing", func([]byte) error {
+		called = true
+		return nil
+	})
+	if err != nil || exists || called {
+		t.Errorf("ViewBytes() = %v, %v, called = %v, want false, nil, false", exists, err, called)
+	}
+
+	if err = s.Set("k", "v"); err != nil {
+		t.Fatal(err)
+	}
+	stop := errors.New("stop")
+	if _, err = s.ViewBytes("k", func([]byte) error { return stop }); !errors.Is(err, stop) {
+		t.Errorf("ViewBytes() error = %v, want the error returned by fn", err)
+	}
+}
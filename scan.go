@@ -0,0 +1,202 @@
+package kv
+
+import (
+	"bytes"
+	"iter"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// ScanOptions 控制 Scan 的迭代行为。
+type ScanOptions struct {
+	// Limit 是最多返回的条目数，0 表示不限制。
+	Limit int
+	// Reverse 为 true 时按键的逆序返回。
+	Reverse bool
+	// KeyOnly 为 true 时只读取键，不读取值，返回的值为零值。
+	// 流式值（SetStream 写入的键）不能作为普通值解码，只在 KeyOnly 为 true 时返回，否则被跳过，也不计入 Limit。
+	KeyOnly bool
+}
+
+// Scan 按前缀遍历默认 Store 中的键值对。
+// 过期的条目总是被跳过。
+// K, V 是泛型参数，代表键和值的类型。
+// prefix: 键的前缀，其编码方式与键相同，例如 Tuple 的前缀元组或字符串前缀。
+// opts: 迭代选项。
+// 返回值:
+// iter.Seq2[K, V]: 键值对迭代器，每次遍历都会开启一个新的只读事务。
+// func() error: 返回最近一次遍历中发生的错误，遍历结束后调用。
+func Scan[K, V any](prefix K, opts ScanOptions) (iter.Seq2[K, V], func() error) {
+	s, err := defaultStore()
+	if err != nil {
+		return func(func(K, V) bool) {}, func() error { return err }
+	}
+	return ScanStore[K, V](s, prefix, opts)
+}
+
+// ScanStore 与 Scan 相同，但作用于指定的 Store。
+func ScanStore[K, V any](s *Store, prefix K, opts ScanOptions) (iter.Seq2[K, V], func() error) {
+	var err error
+	seq := func(yield func(K, V) bool) {
+		var p []byte
+		if p, err = serializeKey(prefix); err != nil {
+			return
+		}
+		err = s.scan(p, opts, func(item *badger.Item) (bool, error) {
+			return yieldItem(s, item, opts.KeyOnly, yield)
+		})
+	}
+	return seq, func() error { return err }
+}
+
+// yieldItem 解码条目的键和值，并交给 yield。
+// 返回值表示是否继续迭代。
+func yieldItem[K, V any](s *Store, item *badger.Item, keyOnly bool, yield func(K, V) bool) (bool, error) {
+	key, err := deserializeKey[K](item.Key())
+	if err != nil {
+		return false, err
+	}
+	var value V
+	if !keyOnly {
+		if err = item.Value(func(val []byte) error {
+			if value, err = unmarshalValue[V](s, item, val); err != nil {
+				return err
+			}
+			return nil
+		}); err != nil {
+			return false, err
+		}
+	}
+	return yield(key, value), nil
+}
+
+// scan 在一个只读事务中遍历所有以 prefix 开头的条目，并对每个条目调用 fn。
+// fn 返回 false 或错误时停止迭代。
+func (s *Store) scan(prefix []byte, opts ScanOptions, fn func(item *badger.Item) (bool, error)) error {
+	_, err := s.iterate(keyRange{prefix: prefix}, opts, fn)
+	return err
+}
+
+// keyRange 描述一次迭代的键范围，所有字段都是编码后的键。
+type keyRange struct {
+	// prefix 是键必须具有的前缀，为空表示不限制。
+	prefix []byte
+	// start 和 end 是范围的下界和上界，nil 表示不限制。
+	start, end []byte
+	// startExclusive 为 true 时不包含 start 本身。
+	startExclusive bool
+	// endInclusive 为 true 时包含 end 本身。
+	endInclusive bool
+}
+
+// before 判断键 k 是否排在范围之前。
+func (r keyRange) before(k []byte) bool {
+	if !bytes.HasPrefix(k, r.prefix) && bytes.Compare(k, r.prefix) < 0 {
+		return true
+	}
+	if r.start != nil {
+		c := bytes.Compare(k, r.start)
+		return c < 0 || (c == 0 && r.startExclusive)
+	}
+	return false
+}
+
+// after 判断键 k 是否排在范围之后。
+func (r keyRange) after(k []byte) bool {
+	if !bytes.HasPrefix(k, r.prefix) && bytes.Compare(k, r.prefix) > 0 {
+		return true
+	}
+	if r.end != nil {
+		c := bytes.Compare(k, r.end)
+		return c > 0 || (c == 0 && !r.endInclusive)
+	}
+	return false
+}
+
+// iterate 在一个只读事务中按顺序遍历范围 r 内的条目，并对每个条目调用 fn。
+// fn 返回 false 或错误时停止迭代。过期的条目总是被跳过，读取值时流式值的清单也被跳过。
+// 返回值:
+// bool: 因达到 Limit 而停止时，表示范围内是否还有更多条目。
+// error: 迭代中发生的任何错误。
+func (s *Store) iterate(r keyRange, opts ScanOptions, fn func(item *badger.Item) (bool, error)) (bool, error) {
+	more := false
+	err := s.db.View(func(txn *badger.Txn) error {
+		iopt := badger.IteratorOptions{
+			PrefetchValues: !opts.KeyOnly,
+			PrefetchSize:   100,
+			Reverse:        opts.Reverse,
+		}
+		// 逆序遍历时起点可能落在前缀之外，因此只在正序遍历时让 Badger 按前缀过滤。
+		if !opts.Reverse {
+			iopt.Prefix = r.prefix
+		}
+		it := txn.NewIterator(iopt)
+		defer it.Close()
+
+		// 确定起点：正序从下界开始，逆序从上界或前缀的后继开始。
+		switch {
+		case !opts.Reverse && r.start != nil && bytes.Compare(r.start, r.prefix) > 0:
+			it.Seek(r.start)
+		case !opts.Reverse:
+			it.Seek(r.prefix)
+		case r.end != nil:
+			it.Seek(r.end)
+		default:
+			if next := prefixSuccessor(r.prefix); next != nil {
+				it.Seek(next)
+			} else {
+				it.Rewind()
+			}
+		}
+
+		// 系统键只对 kv 内部按系统前缀进行的迭代可见。
+		hideSystem := !bytes.HasPrefix(r.prefix, systemPrefix)
+		count := 0
+		for ; it.Valid(); it.Next() {
+			item := it.Item()
+			k := item.Key()
+			if hideSystem && bytes.HasPrefix(k, systemPrefix) {
+				continue
+			}
+			before, after := r.before(k), r.after(k)
+			// 越过了迭代方向上的边界，结束迭代。
+			if (!opts.Reverse && after) || (opts.Reverse && before) {
+				return nil
+			}
+			// 尚未进入范围，例如逆序时恰好等于后继的键或被排除的边界。
+			if before || after || item.IsDeletedOrExpired() {
+				continue
+			}
+			if !opts.KeyOnly && item.UserMeta()&metaStream != 0 {
+				continue
+			}
+			if opts.Limit > 0 && count >= opts.Limit {
+				more = true
+				return nil
+			}
+			ok, err := fn(item)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return nil
+			}
+			count++
+		}
+		return nil
+	})
+	return more, err
+}
+
+// prefixSuccessor 返回比所有以 prefix 开头的键都大的最小字节切片。
+// 如果 prefix 为空或全部是 0xFF，则返回 nil。
+func prefixSuccessor(prefix []byte) []byte {
+	next := bytes.Clone(prefix)
+	for i := len(next) - 1; i >= 0; i-- {
+		if next[i] < 0xFF {
+			next[i]++
+			return next[:i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package kv
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScan(t *testing.T) {
+	s, err := Open(Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	for _, key := range []string{"user:1", "user:2", "user:3", "userx", "v"} {
+		if err = s.Set(key, key+"_value"); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		opts ScanOptions
+		want []string
+	}{
+		{"forward", ScanOptions{}, []string{"user:1", "user:2", "user:3"}},
+		{"reverse", ScanOptions{Reverse: true}, []string{"user:3", "user:2", "user:1"}},
+		{"limit", ScanOptions{Limit: 2}, []string{"user:1", "user:2"}},
+		{"reverse limit", ScanOptions{Reverse: true, Limit: 1}, []string{"user:3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seq, errFn := ScanStore[string, string](s, "user:", tt.opts)
+			var keys []string
+			for k, v := range seq {
+				if v != k+"_value" {
+					t.Errorf("Scan() value = %v, want %v", v, k+"_value")
+				}
+				keys = append(keys, k)
+			}
+			if err := errFn(); err != nil {
+				t.Fatalf("Scan() error = %v", err)
+			}
+			if !reflect.DeepEqual(keys, tt.want) {
+				t.Errorf("Scan() keys = %v, want %v", keys, tt.want)
+			}
+		})
+	}
+
+	seq, errFn := ScanStore[string, string](s, "user:", ScanOptions{KeyOnly: true})
+	for _, v := range seq {
+		if v != "" {
+			t.Errorf("Scan() with KeyOnly value = %v, want empty", v)
+		}
+	}
+	if err = errFn(); err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+}
+
+func TestScan_ReverseSkipsSuccessor(t *testing.T) {
+	s, err := Open(Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	for _, key := range []string{"a1", "a2", "b"} {
+		if err = s.Set(key, 1); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+	}
+	seq, errFn := ScanStore[string, int](s, "a", ScanOptions{Reverse: true})
+	var keys []string
+	for k := range seq {
+		keys = append(keys, k)
+	}
+	if err = errFn(); err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if want := []string{"a2", "a1"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("Scan() keys = %v, want %v", keys, want)
+	}
+}
+
+func TestScan_SkipsStreams(t *testing.T) {
+	s, err := Open(Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	for _, key := range []string{"doc:1", "doc:3"} {
+		if err = s.Set(key, key+"_value"); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+	}
+	if err = s.SetStream("doc:2", strings.NewReader("stream body")); err != nil {
+		t.Fatalf("SetStream() error = %v", err)
+	}
+
+	// Stream manifests cannot be decoded as values, so value scans step over them.
+	for name, opts := range map[string]ScanOptions{"all": {}, "limit": {Limit: 2}} {
+		seq, errFn := ScanStore[string, string](s, "doc:", opts)
+		var keys []string
+		for k := range seq {
+			keys = append(keys, k)
+		}
+		if err = errFn(); err != nil {
+			t.Fatalf("%s: Scan() error = %v", name, err)
+		}
+		if want := []string{"doc:1", "doc:3"}; !reflect.DeepEqual(keys, want) {
+			t.Errorf("%s: Scan() keys = %v, want %v", name, keys, want)
+		}
+	}
+	seq, done := RangeStore[string, string](s, "doc:", "doc:9", RangeOptions{})
+	var keys []string
+	for k := range seq {
+		keys = append(keys, k)
+	}
+	if _, err = done(); err != nil || len(keys) != 2 {
+		t.Errorf("Range() = %v, %v, want the two plain values", keys, err)
+	}
+
+	// Key-only scans do not decode values and still report the stream key.
+	seq, errFn := ScanStore[string, string](s, "doc:", ScanOptions{KeyOnly: true})
+	keys = nil
+	for k := range seq {
+		keys = append(keys, k)
+	}
+	if err = errFn(); err != nil || len(keys) != 3 {
+		t.Errorf("Scan(KeyOnly) = %v, %v, want all three keys", keys, err)
+	}
+}
@@ -0,0 +1,427 @@
+package kv
+
+import (
+	"context"
+	"reflect"
+	"sync"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/pkg/errors"
+)
+
+/*
+schema 版本用于在值类型的结构发生变化后继续读取旧数据。
+为类型注册了迁移函数之后，写入该类型的值时会在编码结果前加上一个版本字节，并在 UserMeta 中设置 metaVersioned 位。
+读取时如果存储的版本低于当前版本，依次调用 N→N+1 的迁移函数，直到当前版本，然后再解码。
+注册迁移函数之前写入的值没有版本字节，视为版本 0。
+*/
+
+// metaVersioned 是 UserMeta 中表示值带有 schema 版本字节的位。
+const metaVersioned byte = 1 << 1
+
+// ErrNoMigration 表示存储的值的版本与当前版本之间缺少迁移函数，或者存储的版本比当前版本更新。
+var ErrNoMigration = errors.New("no migration")
+
+// Migration 将版本 N 的值的编码转换为版本 N+1 的编码。
+// codec 是读取时使用的编解码器，data 不包含版本字节。
+type Migration func(codec Codec, data []byte) ([]byte, error)
+
+// MigrateFunc 将一个类型化的转换函数包装为 Migration：
+// 使用 codec 将旧数据解码为 Old，调用 f，再将结果编码。
+// Old 通常是类型旧版本结构的副本。
+func MigrateFunc[Old, New any](f func(Old) (New, error)) Migration {
+	return func(codec Codec, data []byte) ([]byte, error) {
+		var old Old
+		if err := codec.Unmarshal(data, &old); err != nil {
+			return nil, err
+		}
+		value, err := f(old)
+		if err != nil {
+			return nil, err
+		}
+		return codec.Marshal(value)
+	}
+}
+
+// schema 记录一个值类型的当前版本和迁移函数。
+type schema struct {
+	version    uint8
+	migrations map[uint8]Migration
+}
+
+var (
+	schemasMu sync.RWMutex
+	schemas   = map[reflect.Type]*schema{}
+)
+
+// RegisterMigration 为类型 V 注册从版本 from 到 from+1 的迁移函数。
+// V 的当前版本是所有已注册迁移函数的目标版本中最大的一个。
+// 应在写入或读取 V 之前注册，通常在 init 中调用。
+func RegisterMigration[V any](from uint8, m Migration) {
+	if from == 255 {
+		panic("kv: schema version overflow")
+	}
+	t := reflect.TypeFor[V]()
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	schemasMu.Lock()
+	defer schemasMu.Unlock()
+	sc := schemas[t]
+	if sc == nil {
+		sc = &schema{migrations: map[uint8]Migration{}}
+		schemas[t] = sc
+	}
+	sc.migrations[from] = m
+	sc.version = max(sc.version, from+1)
+}
+
+// schemaOf 返回类型 t 的 schema，指针类型使用其元素类型。未注册时返回 nil。
+func schemaOf(t reflect.Type) *schema {
+	if t == nil {
+		return nil
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	schemasMu.RLock()
+	defer schemasMu.RUnlock()
+	return schemas[t]
+}
+
+// migrate 去掉 data 中的版本字节，并将其迁移到类型 t 的当前版本。
+// 返回迁移后的数据，以及是否进行了迁移。
+func migrate(codec Codec, t reflect.Type, data []byte, meta byte) ([]byte, bool, error) {
+	sc := schemaOf(t)
+	var version uint8
+	if meta&metaVersioned != 0 {
+		if len(data) == 0 {
+			return nil, false, errors.Wrap(errInvalidLength, "missing schema version")
+		}
+		version, data = data[0], data[1:]
+	}
+	if sc == nil || data == nil || version == sc.version {
+		return data, false, nil
+	}
+	if version > sc.version {
+		return nil, false, errors.Wrapf(ErrNoMigration, "version %d of %s is newer than %d", version, t, sc.version)
+	}
+	for v := version; v < sc.version; v++ {
+		m := sc.migrations[v]
+		if m == nil {
+			return nil, false, errors.Wrapf(ErrNoMigration, "%s from version %d", t, v)
+		}
+		var err error
+		if data, err = m(codec, data); err != nil {
+			return nil, false, errors.Wrapf(err, "migrate %s from version %d", t, v)
+		}
+	}
+	return data, true, nil
+}
+
+// outdated 判断条目的值是否是类型 t 的旧版本，不解码值。
+// 只有能证明值属于 t 时才认为是旧版本：值带有版本字节，或者带有与 t 一致的类型指纹的信封。
+// 既没有版本字节也没有信封的值只有在 unversioned 为 true 时才认为是 t 的版本 0。
+// 信封、密封或压缩的数据损坏的值无法迁移，同样被跳过。
+// 返回值:
+// bool: 值是否需要迁移。
+// bool: 值是否被跳过。
+// error: 读取值时发生的任何错误。
+func (s *Store) outdated(item *badger.Item, t reflect.Type, unversioned bool) (bool, bool, error) {
+	sc := schemaOf(t)
+	meta := item.UserMeta()
+	// nil 值没有内容需要迁移，流式值不是 t 的值。
+	if sc == nil || meta&metaStream != 0 || item.ValueSize() == 0 {
+		return false, false, nil
+	}
+	var old, skip bool
+	err := item.Value(func(val []byte) error {
+		if meta&metaEnvelope != 0 {
+			env, _, err := parseEnvelope(val)
+			if err != nil || !env.matches(t) {
+				skip = true
+				return nil
+			}
+		}
+		if meta&metaVersioned == 0 {
+			old = meta&metaEnvelope != 0 || unversioned
+			skip = !old
+			return nil
+		}
+		payload, err := s.rawValue(item.Key(), val, meta)
+		if err != nil {
+			skip = true
+			return nil
+		}
+		old = len(payload) > 0 && payload[0] < sc.version
+		return nil
+	})
+	return old, skip, err
+}
+
+// defaultMigrateBatch 是 MigrateOptions.BatchSize 的默认值。
+const defaultMigrateBatch = 100
+
+// MigrateOptions 是 Migrate 的选项。
+type MigrateOptions struct {
+	// BatchSize 是每个读写事务中最多检查的键数，0 表示使用默认值 100。
+	BatchSize int
+	// Progress 如果不为 nil，在每一批完成后以到目前为止的累计进度调用。
+	Progress func(MigrateStats)
+	// Unversioned 为 true 时，既没有 schema 版本字节也没有信封的值也被当作 V 的版本 0 迁移，
+	// 例如在为 V 注册迁移函数之前、未启用 Options.Envelope 时写入的值。
+	// 这些值无法证明属于 V，同一前缀下其他类型的值如果恰好能被解码为 V 会被改写，
+	// 因此只应在前缀下只有 V 的值时使用。默认跳过这些值。
+	Unversioned bool
+}
+
+// MigrateStats 是迁移的进度。
+type MigrateStats struct {
+	// Scanned 是已检查的键数。
+	Scanned int
+	// Migrated 是迁移并写回的键数。
+	Migrated int
+	// Skipped 是跳过的值的数量，包括无法证明属于目标类型的值和无法解码为目标类型的值，
+	// 例如同一前缀下其他类型的值，这些值保持不变。
+	Skipped int
+}
+
+// Migrate 将默认 Store 中以 prefix 开头的、类型为 V 的旧版本值迁移到当前版本并写回。
+// 只迁移能证明属于 V 的值：带有 schema 版本字节，或者带有类型指纹与 V 一致的信封；
+// 其他值以及无法解码为 V 的值被跳过并计入 MigrateStats.Skipped，见 MigrateOptions.Unversioned。
+// 键按批处理，每批使用一个读写事务，过期时间保持不变，迁移过程中被并发修改的键会被重新检查。
+// 缺少迁移函数时返回 ErrNoMigration。
+// 需要在后台运行时使用 MigrateInBackground。
+// V 是泛型参数，代表值的类型。
+// prefix: 键的前缀，编码方式与 Scan 相同。
+// opts: 迁移选项。
+// 返回值:
+// MigrateStats: 迁移的进度，出错时为出错之前的进度。
+// error: 操作中发生的任何错误。
+func Migrate[V any](prefix any, opts MigrateOptions) (MigrateStats, error) {
+	s, err := defaultStore()
+	if err != nil {
+		return MigrateStats{}, err
+	}
+	return MigrateStore[V](s, prefix, opts)
+}
+
+// MigrateStore 与 Migrate 相同，但作用于指定的 Store。
+func MigrateStore[V any](s *Store, prefix any, opts MigrateOptions) (MigrateStats, error) {
+	return s.migrate(context.Background(), reflect.TypeFor[V](), prefix, opts)
+}
+
+// MigrateTask 是在后台运行的迁移任务。
+type MigrateTask struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu    sync.Mutex
+	stats MigrateStats
+	err   error
+}
+
+// MigrateInBackground 在一个新的 goroutine 中对默认 Store 运行 Migrate，并立即返回任务。
+// opts.Progress 在该 goroutine 中调用。
+func MigrateInBackground[V any](prefix any, opts MigrateOptions) (*MigrateTask, error) {
+	s, err := defaultStore()
+	if err != nil {
+		return nil, err
+	}
+	return MigrateStoreInBackground[V](s, prefix, opts), nil
+}
+
+// MigrateStoreInBackground 与 MigrateInBackground 相同，但作用于指定的 Store。
+func MigrateStoreInBackground[V any](s *Store, prefix any, opts MigrateOptions) *MigrateTask {
+	ctx, cancel := context.WithCancel(context.Background())
+	task := &MigrateTask{cancel: cancel, done: make(chan struct{})}
+	progress := opts.Progress
+	opts.Progress = func(stats MigrateStats) {
+		task.mu.Lock()
+		task.stats = stats
+		task.mu.Unlock()
+		if progress != nil {
+			progress(stats)
+		}
+	}
+	go func() {
+		defer close(task.done)
+		defer cancel()
+		stats, err := s.migrate(ctx, reflect.TypeFor[V](), prefix, opts)
+		task.mu.Lock()
+		task.stats, task.err = stats, err
+		task.mu.Unlock()
+	}()
+	return task
+}
+
+// Progress 返回任务到目前为止的进度。
+func (t *MigrateTask) Progress() MigrateStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.stats
+}
+
+// Cancel 请求停止任务。任务在当前批完成后停止，Wait 返回 context.Canceled。
+func (t *MigrateTask) Cancel() {
+	t.cancel()
+}
+
+// Done 返回一个在任务结束时关闭的通道。
+func (t *MigrateTask) Done() <-chan struct{} {
+	return t.done
+}
+
+// Wait 等待任务结束，返回最终的进度和任务中发生的错误。
+func (t *MigrateTask) Wait() (MigrateStats, error) {
+	<-t.done
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.stats, t.err
+}
+
+// migrate 按批迁移以 prefix 开头的类型为 t 的旧版本值，每批之间检查 ctx 是否已取消。
+func (s *Store) migrate(ctx context.Context, t reflect.Type, prefix any, opts MigrateOptions) (MigrateStats, error) {
+	var stats MigrateStats
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if schemaOf(t) == nil {
+		return stats, nil
+	}
+	p, err := serializeKey(prefix)
+	if err != nil {
+		return stats, err
+	}
+	batch := opts.BatchSize
+	if batch <= 0 {
+		batch = defaultMigrateBatch
+	}
+
+	// 每批先在只读事务中找出需要迁移的键，再在一个读写事务中迁移它们，避免长时间占用读写事务。
+	r := keyRange{prefix: p}
+	for {
+		if err = ctx.Err(); err != nil {
+			return stats, err
+		}
+		var keys [][]byte
+		more, err := s.iterate(r, ScanOptions{Limit: batch}, func(item *badger.Item) (bool, error) {
+			r.start, r.startExclusive = item.KeyCopy(nil), true
+			stats.Scanned++
+			old, skip, err := s.outdated(item, t, opts.Unversioned)
+			if old {
+				keys = append(keys, item.KeyCopy(nil))
+			}
+			if skip {
+				stats.Skipped++
+			}
+			return true, err
+		})
+		if err != nil {
+			return stats, err
+		}
+		migrated, skipped, err := s.migrateKeys(keys, t, opts.Unversioned)
+		if err != nil {
+			return stats, err
+		}
+		stats.Migrated += migrated
+		stats.Skipped += skipped
+		if opts.Progress != nil {
+			opts.Progress(stats)
+		}
+		if !more {
+			return stats, nil
+		}
+	}
+}
+
+// migrateKeys 在一个读写事务中将 keys 的值迁移为类型 t 的当前版本并写回，过期时间保持不变。
+// 每个值都用 outdated 重新检查，已被并发修改为当前版本或其他类型的值不会被写回。
+// 返回值:
+// int: 写回的键数。
+// int: 因无法解码为 t 而跳过的键数。
+// error: 操作中发生的任何错误，缺少迁移函数时为 ErrNoMigration。
+func (s *Store) migrateKeys(keys [][]byte, t reflect.Type, unversioned bool) (int, int, error) {
+	if len(keys) == 0 {
+		return 0, 0, nil
+	}
+	var migrated, skipped int
+	err := s.update(func(txn *badger.Txn) error {
+		// 冲突重试时整个事务重新执行，计数需要从头开始。
+		migrated, skipped = 0, 0
+		for _, k := range keys {
+			item, err := txn.Get(k)
+			if err != nil {
+				if errors.Is(err, badger.ErrKeyNotFound) {
+					continue
+				}
+				return err
+			}
+			old, _, err := s.outdated(item, t, unversioned)
+			if err != nil {
+				return err
+			}
+			if !old {
+				continue
+			}
+			value := reflect.New(t).Interface()
+			var ok bool
+			var decodeErr error
+			if err = item.Value(func(val []byte) error {
+				ok, decodeErr = s.decode(item, val, value)
+				return nil
+			}); err != nil {
+				return err
+			}
+			switch {
+			case errors.Is(decodeErr, ErrNoMigration):
+				return decodeErr
+			case decodeErr != nil:
+				skipped++
+				continue
+			case !ok:
+				continue
+			}
+			if err = s.writeBack(txn, k, value, item.ExpiresAt()); err != nil {
+				return err
+			}
+			migrated++
+		}
+		return nil
+	})
+	return migrated, skipped, err
+}
+
+// writeBackIf 将 Get 迁移后的值写回键 k。
+// 只有当条目的 Badger 版本仍为 version 时才写入，避免覆盖并发写入的值。
+func (s *Store) writeBackIf(k []byte, version uint64, value any) error {
+	err := s.db.Update(func(txn *badger.Txn) error {
+		item, err := txn.Get(k)
+		if err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return nil
+			}
+			return err
+		}
+		if item.Version() != version {
+			return nil
+		}
+		return s.writeBack(txn, k, value, item.ExpiresAt())
+	})
+	// 冲突说明键已被并发修改，新值不需要再写回。
+	if errors.Is(err, badger.ErrConflict) {
+		return nil
+	}
+	return err
+}
+
+// writeBack 在事务中以当前版本重新写入键 k 的值，过期时间设置为 expiresAt（Unix 秒，0 表示永不过期）。
+func (s *Store) writeBack(txn *badger.Txn, k []byte, value any, expiresAt uint64) error {
+	data, meta, err := s.marshal(k, value)
+	if err != nil {
+		return err
+	}
+	entry := badger.NewEntry(k, data).WithMeta(meta)
+	entry.ExpiresAt = expiresAt
+	return txn.SetEntry(entry)
+}
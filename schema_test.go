@@ -0,0 +1,274 @@
+package kv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// schemaUserV0 is the layout schemaUser had before version 1.
+type schemaUserV0 struct {
+	FullName string
+}
+
+type schemaUser struct {
+	First, Last string
+}
+
+// schemaOrphan has a migration from version 1 only, so version 0 values cannot be read.
+type schemaOrphan struct {
+	Name string
+}
+
+func init() {
+	RegisterMigration[schemaUser](0, MigrateFunc(func(old schemaUserV0) (schemaUser, error) {
+		first, last, _ := strings.Cut(old.FullName, " ")
+		return schemaUser{First: first, Last: last}, nil
+	}))
+	RegisterMigration[schemaOrphan](1, func(_ Codec, data []byte) ([]byte, error) { return data, nil })
+}
+
+func userMeta(t *testing.T, s *Store, key string) byte {
+	t.Helper()
+	k, _ := serializeKey(key)
+	var meta byte
+	if err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(k)
+		if err != nil {
+			return err
+		}
+		meta = item.UserMeta()
+		return nil
+	}); err != nil {
+		t.Fatalf("read meta: %v", err)
+	}
+	return meta
+}
+
+func TestMigration_Get(t *testing.T) {
+	for _, writeBack := range []bool{false, true} {
+		s, err := Open(Options{MigrateOnRead: writeBack})
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+
+		// Values written with the old layout carry no version byte.
+		if err = s.Set("user", schemaUserV0{FullName: "Ada Lovelace"}); err != nil {
+			t.Fatal(err)
+		}
+		var got schemaUser
+		if _, err = s.Get("user", &got); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if got != (schemaUser{First: "Ada", Last: "Lovelace"}) {
+			t.Errorf("Get() = %+v, want migrated value", got)
+		}
+
+		versioned := userMeta(t, s, "user")&metaVersioned != 0
+		if versioned != writeBack {
+			t.Errorf("MigrateOnRead = %v, but stored value versioned = %v", writeBack, versioned)
+		}
+		_ = s.Close()
+	}
+}
+
+func TestMigration_Current(t *testing.T) {
+	s, err := Open(Options{Envelope: true})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	want := schemaUser{First: "Alan", Last: "Turing"}
+	if err = s.Set("user", want); err != nil {
+		t.Fatal(err)
+	}
+	if userMeta(t, s, "user")&metaVersioned == 0 {
+		t.Error("values of a registered type should carry a version byte")
+	}
+	var got schemaUser
+	if _, err = s.Get("user", &got); err != nil || got != want {
+		t.Errorf("Get() = %+v, %v, want %+v", got, err, want)
+	}
+}
+
+func TestMigrateStore(t *testing.T) {
+	s, err := Open(Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	for _, name := range []string{"a", "b", "c"} {
+		if err = s.Set("users:"+name, schemaUserV0{FullName: name + " x"}, time.Hour.Milliseconds()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = s.Set("users:d", schemaUser{First: "d"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A value of another type under the prefix is skipped, not fatal.
+	if err = s.Set("users:z", []string{"not", "a", "user"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var reports int
+	stats, err := MigrateStore[schemaUser](s, "users:", MigrateOptions{
+		BatchSize:   2,
+		Progress:    func(MigrateStats) { reports++ },
+		Unversioned: true,
+	})
+	if err != nil {
+		t.Fatalf("MigrateStore() error = %v", err)
+	}
+	if stats != (MigrateStats{Scanned: 5, Migrated: 3, Skipped: 1}) {
+		t.Errorf("MigrateStore() = %+v, want 5 scanned, 3 migrated, 1 skipped", stats)
+	}
+	if reports != 3 {
+		t.Errorf("Progress called %d times, want once per batch (3)", reports)
+	}
+	if stats, _ = MigrateStore[schemaUser](s, "users:", MigrateOptions{Unversioned: true}); stats.Migrated != 0 {
+		t.Errorf("second MigrateStore() = %+v, want nothing migrated", stats)
+	}
+	var other []string
+	if _, err = s.Get("users:z", &other); err != nil || len(other) != 3 {
+		t.Errorf("skipped value = %v, %v, want it unchanged", other, err)
+	}
+
+	if userMeta(t, s, "users:a")&metaVersioned == 0 {
+		t.Error("migrated value should carry a version byte")
+	}
+	if ttl, _, _ := s.TTL("users:a"); ttl <= 0 {
+		t.Errorf("TTL() = %v, migration should keep the expiry", ttl)
+	}
+}
+
+// schemaForeign is another type that gob can decode into schemaUserV0, losing Color.
+type schemaForeign struct {
+	FullName, Color string
+}
+
+func TestMigrateStore_MixedPrefix(t *testing.T) {
+	s, err := Open(Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	// Old schemaUser values whose origin is provable: one behind an envelope, one with a version byte.
+	payload, _ := HybridCodec.Marshal(schemaUserV0{FullName: "Grace Hopper"})
+	env := envelope{version: envelopeVersion, codec: codecID(HybridCodec), fingerprint: typeFingerprint(reflect.TypeFor[schemaUser]())}
+	raw := map[string]*badger.Entry{
+		"mix:enveloped": badger.NewEntry(nil, appendEnvelope(env, payload)).WithMeta(metaEnvelope | metaNative),
+		"mix:versioned": badger.NewEntry(nil, append([]byte{0}, payload...)).WithMeta(metaVersioned | metaNative),
+	}
+	if err = s.db.Update(func(txn *badger.Txn) error {
+		for key, e := range raw {
+			e.Key, _ = serializeKey(key)
+			if err := txn.SetEntry(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// Foreign values, with and without an envelope, must be left alone.
+	foreign := schemaForeign{FullName: "Linus Torvalds", Color: "blue"}
+	if err = s.Set("mix:foreign", foreign); err != nil {
+		t.Fatal(err)
+	}
+	enveloped := s.WithCodec(HybridCodec)
+	enveloped.opts.Envelope = true
+	if err = enveloped.Set("mix:foreign_env", foreign); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := MigrateStore[schemaUser](s, "mix:", MigrateOptions{})
+	if err != nil {
+		t.Fatalf("MigrateStore() error = %v", err)
+	}
+	if stats != (MigrateStats{Scanned: 4, Migrated: 2, Skipped: 2}) {
+		t.Errorf("MigrateStore() = %+v, want 4 scanned, 2 migrated, 2 skipped", stats)
+	}
+	for _, key := range []string{"mix:enveloped", "mix:versioned"} {
+		var got schemaUser
+		if _, err = s.Get(key, &got); err != nil || got != (schemaUser{First: "Grace", Last: "Hopper"}) {
+			t.Errorf("Get(%q) = %+v, %v, want migrated value", key, got, err)
+		}
+		if userMeta(t, s, key)&metaVersioned == 0 {
+			t.Errorf("%s should carry a version byte after migration", key)
+		}
+	}
+	for _, key := range []string{"mix:foreign", "mix:foreign_env"} {
+		var got schemaForeign
+		if _, err = s.Get(key, &got); err != nil || got != foreign {
+			t.Errorf("Get(%q) = %+v, %v, want it unchanged", key, got, err)
+		}
+	}
+}
+
+func TestMigrateStoreInBackground(t *testing.T) {
+	s, err := Open(Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	for i := range 10 {
+		if err = s.Set(fmt.Sprintf("bg:%02d", i), schemaUserV0{FullName: "a b"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	task := MigrateStoreInBackground[schemaUser](s, "bg:", MigrateOptions{BatchSize: 3, Unversioned: true})
+	stats, err := task.Wait()
+	if err != nil || stats.Migrated != 10 {
+		t.Fatalf("Wait() = %+v, %v, want 10 migrated", stats, err)
+	}
+	if task.Progress() != stats {
+		t.Errorf("Progress() = %+v, want %+v", task.Progress(), stats)
+	}
+
+	// A canceled task stops before the next batch.
+	for i := range 10 {
+		if err = s.Set(fmt.Sprintf("bg:%02d", i), schemaUserV0{FullName: "a b"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ready := make(chan struct{})
+	task = MigrateStoreInBackground[schemaUser](s, "bg:", MigrateOptions{
+		BatchSize:   1,
+		Unversioned: true,
+		Progress: func(MigrateStats) {
+			<-ready
+			task.Cancel()
+		},
+	})
+	close(ready)
+	if stats, err = task.Wait(); !errors.Is(err, context.Canceled) || stats.Migrated != 1 {
+		t.Errorf("Wait() = %+v, %v, want context.Canceled after one batch", stats, err)
+	}
+}
+
+func TestMigration_Missing(t *testing.T) {
+	s, err := Open(Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	if err = s.Set("orphan", "legacy"); err != nil {
+		t.Fatal(err)
+	}
+	var got schemaOrphan
+	if _, err = s.Get("orphan", &got); !errors.Is(err, ErrNoMigration) {
+		t.Errorf("Get() error = %v, want ErrNoMigration", err)
+	}
+}
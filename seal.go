@@ -0,0 +1,386 @@
+package kv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/pkg/errors"
+)
+
+/*
+密封 (seal) 是值层面的信封加密，与 Badger 的整库加密相互独立。
+Options.SealedPrefixes 中的前缀各有一个随机生成的数据密钥，数据密钥用 Options.MasterKey 以 AES-GCM 加密后
+保存在系统键 systemPrefix + "seal/" + 前缀 中；这些前缀下的值在压缩之后、信封之前用数据密钥以 AES-GCM 加密，
+并在 UserMeta 中设置 metaSealed 位。因此备份和导出的数据中不会出现这些值的明文，没有主密钥也无法解密。
+
+密封后的值为 uvarint 前缀长度 + nonce(12) + 密文，前缀长度指出使用哪个前缀的数据密钥，
+附加数据 (AAD) 是值所在的键，密文无法被挪到另一个键下。SetStream 写入的块同样被密封，附加数据是块的键。
+
+密封只在写入时进行。在 SealedPrefixes 中加入新的前缀后，该前缀下已有的值仍是明文，直到被重新写入；
+Reseal 会找出这些值并就地密封，不需要解码，过期时间保持不变。加入前缀之后应执行一次 Reseal。
+Reseal 写入的新版本标记为丢弃更早的版本，因此之后的备份不再包含明文；但旧版本仍留在 SST 文件和 value log 中，
+直到 Badger 的压缩 (compaction) 和 value log GC 将其回收，在此之前直接读取磁盘文件仍可能看到明文。
+*/
+
+// metaSealed 是 UserMeta 中表示值被密封的位。
+const metaSealed byte = 1 << 4
+
+// ErrWrongMasterKey 表示数据库中的数据密钥不是用 Options.MasterKey 加密的。
+var ErrWrongMasterKey = errors.New("wrong master key")
+
+// dataKeySize 是数据密钥的字节数，数据密钥使用 AES-256。
+const dataKeySize = 32
+
+// resealBatch 是 Reseal 每个读写事务中最多处理的键数。
+const resealBatch = 100
+
+// dataKey 是一个前缀的数据密钥。
+type dataKey struct {
+	aead cipher.AEAD
+	// wrapped 是用主密钥加密后的数据密钥，即系统键中保存的内容。
+	wrapped []byte
+}
+
+// sealKey 返回前缀的数据密钥的系统键。
+func sealKey(prefix string) []byte {
+	return append(append(bytes.Clone(systemPrefix), "seal/"...), prefix...)
+}
+
+// newGCM 返回密钥 key 的 AES-GCM。
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealWith 用 aead 加密 data，结果为 nonce + 密文。
+func sealWith(aead cipher.AEAD, dst, data, aad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(append(dst, nonce...), nonce, data, aad), nil
+}
+
+// openWith 解密 sealWith 生成的数据。
+func openWith(aead cipher.AEAD, data, aad []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("sealed value too short")
+	}
+	n := aead.NonceSize()
+	return aead.Open(nil, data[:n], data[n:], aad)
+}
+
+// loadSealKeys 用 Options.MasterKey 解密数据库中所有的数据密钥，
+// 并为 Options.SealedPrefixes 中还没有数据密钥的前缀生成一个。
+func (s *Store) loadSealKeys() error {
+	s.sealKeys = map[string]dataKey{}
+	if len(s.opts.MasterKey) == 0 {
+		if len(s.opts.SealedPrefixes) > 0 {
+			return errors.New("SealedPrefixes requires a MasterKey")
+		}
+		return nil
+	}
+	if err := checkEncryptionKey(s.opts.MasterKey); err != nil {
+		return errors.Wrap(err, "master key")
+	}
+	master, err := newGCM(s.opts.MasterKey)
+	if err != nil {
+		return err
+	}
+
+	base := sealKey("")
+	if err = s.scan(base, ScanOptions{}, func(item *badger.Item) (bool, error) {
+		prefix := string(item.Key()[len(base):])
+		return true, item.Value(func(val []byte) error {
+			key, err := openWith(master, val, []byte(prefix))
+			if err != nil {
+				return errors.Wrapf(ErrWrongMasterKey, "data key of prefix %q", prefix)
+			}
+			aead, err := newGCM(key)
+			s.sealKeys[prefix] = dataKey{aead: aead, wrapped: bytes.Clone(val)}
+			return err
+		})
+	}); err != nil {
+		return err
+	}
+
+	// 只读时不会写入新的值，不需要生成数据密钥。
+	if s.opts.ReadOnly {
+		return nil
+	}
+	for _, prefix := range s.opts.SealedPrefixes {
+		if _, ok := s.sealKeys[prefix]; ok {
+			continue
+		}
+		key := make([]byte, dataKeySize)
+		if _, err = rand.Read(key); err != nil {
+			return err
+		}
+		wrapped, err := sealWith(master, nil, key, []byte(prefix))
+		if err != nil {
+			return err
+		}
+		aead, err := newGCM(key)
+		if err != nil {
+			return err
+		}
+		s.sealKeys[prefix] = dataKey{aead: aead, wrapped: wrapped}
+	}
+	return s.storeSealKeys()
+}
+
+// storeSealKeys 将所有数据密钥写入数据库。
+func (s *Store) storeSealKeys() error {
+	if len(s.sealKeys) == 0 {
+		return nil
+	}
+	return s.db.Update(func(txn *badger.Txn) error {
+		for prefix, dk := range s.sealKeys {
+			if err := txn.Set(sealKey(prefix), dk.wrapped); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// sealedPrefix 返回键 k 在 Options.SealedPrefixes 中最长的匹配前缀。
+func (s *Store) sealedPrefix(k []byte) (string, bool) {
+	var match string
+	found := false
+	for _, prefix := range s.opts.SealedPrefixes {
+		if (!found || len(prefix) > len(match)) && bytes.HasPrefix(k, []byte(prefix)) {
+			match, found = prefix, true
+		}
+	}
+	return match, found
+}
+
+// seal 如果键 k 属于密封的前缀，则用前缀的数据密钥加密 data，aad 是附加数据。
+// 返回值:
+// []byte: 密封后的数据，不需要密封时返回 data 本身。
+// bool: 是否进行了密封。
+// error: 加密过程中发生的任何错误。
+func (s *Store) seal(k, aad, data []byte) ([]byte, bool, error) {
+	prefix, ok := s.sealedPrefix(k)
+	if !ok {
+		return data, false, nil
+	}
+	// 只读打开时不会为新的前缀生成数据密钥。
+	dk, ok := s.sealKeys[prefix]
+	if !ok {
+		return nil, false, errors.Errorf("no data key for sealed prefix %q", prefix)
+	}
+	dst := binary.AppendUvarint(nil, uint64(len(prefix)))
+	out, err := sealWith(dk.aead, dst, data, aad)
+	if err != nil {
+		return nil, false, err
+	}
+	return out, true, nil
+}
+
+// unseal 解密 seal 生成的数据，k 和 aad 必须与密封时相同。
+func (s *Store) unseal(k, aad, data []byte) ([]byte, error) {
+	n, size := binary.Uvarint(data)
+	if size <= 0 || n > uint64(len(k)) {
+		return nil, errors.New("invalid sealed value")
+	}
+	prefix := string(k[:n])
+	dk, ok := s.sealKeys[prefix]
+	if !ok {
+		return nil, errors.Errorf("no data key for sealed prefix %q", prefix)
+	}
+	out, err := openWith(dk.aead, data[size:], aad)
+	return out, errors.Wrap(err, "unseal")
+}
+
+// overlapsSealed 判断以 prefix 开头的键是否可能属于密封的前缀。
+func (s *Store) overlapsSealed(prefix []byte) bool {
+	for _, p := range s.opts.SealedPrefixes {
+		if bytes.HasPrefix(prefix, []byte(p)) || bytes.HasPrefix([]byte(p), prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reseal 密封默认 Store 中 Options.SealedPrefixes 下还没有密封的值，包括流式值的块。
+// 返回值:
+// int: 被密封的值的数量，一个流式值计为一个。
+// error: 操作中发生的任何错误。
+func Reseal() (int, error) {
+	s, err := defaultStore()
+	if err != nil {
+		return 0, err
+	}
+	return s.Reseal()
+}
+
+// Reseal 密封密封前缀下还没有密封的值，语义与包级别的 Reseal 相同。
+func (s *Store) Reseal() (int, error) {
+	if s.opts.ReadOnly {
+		return 0, errors.New("cannot reseal a read-only store")
+	}
+	var n int
+	for _, prefix := range s.opts.SealedPrefixes {
+		r := keyRange{prefix: []byte(prefix)}
+		for {
+			var keys [][]byte
+			more, err := s.iterate(r, ScanOptions{Limit: resealBatch, KeyOnly: true}, func(item *badger.Item) (bool, error) {
+				r.start, r.startExclusive = item.KeyCopy(nil), true
+				if item.UserMeta()&metaSealed == 0 {
+					keys = append(keys, item.KeyCopy(nil))
+				}
+				return true, nil
+			})
+			if err != nil {
+				return n, err
+			}
+			sealed, streams, err := s.resealKeys(keys)
+			n += sealed
+			if err != nil {
+				return n, err
+			}
+			for k, id := range streams {
+				sealed, err := s.resealStream([]byte(k), id)
+				if err != nil {
+					return n, err
+				}
+				if sealed {
+					n++
+				}
+			}
+			if !more {
+				break
+			}
+		}
+	}
+	return n, nil
+}
+
+// resealKeys 在一个读写事务中密封 keys 中还没有密封的值，并保持过期时间不变。
+// 返回值:
+// int: 被密封的值的数量。
+// map[string][]byte: keys 中的流式值，键是清单所在的键，值是流 ID，它们的块由 resealStream 密封。
+// error: 操作中发生的任何错误。
+func (s *Store) resealKeys(keys [][]byte) (int, map[string][]byte, error) {
+	if len(keys) == 0 {
+		return 0, nil, nil
+	}
+	var n int
+	var streams map[string][]byte
+	err := s.update(func(txn *badger.Txn) error {
+		n, streams = 0, map[string][]byte{}
+		for _, k := range keys {
+			item, err := txn.Get(k)
+			if err != nil {
+				if errors.Is(err, badger.ErrKeyNotFound) {
+					continue
+				}
+				return err
+			}
+			meta := item.UserMeta()
+			if meta&metaSealed != 0 {
+				continue
+			}
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			if meta&metaStream != 0 {
+				m, err := decodeManifest(val)
+				if err != nil {
+					return corrupt(item, err)
+				}
+				streams[string(k)] = m.id
+				continue
+			}
+			// nil 值没有内容需要密封。
+			if len(val) == 0 {
+				continue
+			}
+			// 信封头部保持明文，其后的数据与写入时交给 seal 的数据相同。
+			var header []byte
+			if meta&metaEnvelope != 0 {
+				if len(val) < envelopeSize {
+					return corrupt(item, errors.New("insufficient data for envelope"))
+				}
+				header, val = bytes.Clone(val[:envelopeSize]), val[envelopeSize:]
+			}
+			data, sealed, err := s.seal(k, k, val)
+			if err != nil || !sealed {
+				return err
+			}
+			entry := badger.NewEntry(k, append(header, data...)).WithMeta(meta | metaSealed).WithDiscard()
+			entry.ExpiresAt = item.ExpiresAt()
+			if err = txn.SetEntry(entry); err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	})
+	return n, streams, err
+}
+
+// resealStream 密封键 k 上流 ID 的块中还没有密封的块，附加数据是块的键，返回是否密封了任何块。
+// 每批在一个读写事务中重新读取块，已被 CollectStreams 删除的块不会被重新写入。
+func (s *Store) resealStream(k, id []byte) (bool, error) {
+	r := keyRange{prefix: append(append(bytes.Clone(systemPrefix), "c/"...), id...)}
+	var resealed bool
+	for {
+		var chunks [][]byte
+		more, err := s.iterate(r, ScanOptions{Limit: resealBatch, KeyOnly: true}, func(item *badger.Item) (bool, error) {
+			r.start, r.startExclusive = item.KeyCopy(nil), true
+			if item.UserMeta()&metaSealed == 0 {
+				chunks = append(chunks, item.KeyCopy(nil))
+			}
+			return true, nil
+		})
+		if err != nil {
+			return resealed, err
+		}
+		var sealedAny bool
+		if err = s.update(func(txn *badger.Txn) error {
+			sealedAny = false
+			for _, ck := range chunks {
+				item, err := txn.Get(ck)
+				if err != nil {
+					if errors.Is(err, badger.ErrKeyNotFound) {
+						continue
+					}
+					return err
+				}
+				if item.UserMeta()&metaSealed != 0 {
+					continue
+				}
+				val, err := item.ValueCopy(nil)
+				if err != nil {
+					return err
+				}
+				data, sealed, err := s.seal(k, ck, val)
+				if err != nil || !sealed {
+					return err
+				}
+				if err = txn.SetEntry(badger.NewEntry(ck, data).WithMeta(item.UserMeta() | metaSealed).WithDiscard()); err != nil {
+					return err
+				}
+				sealedAny = true
+			}
+			return nil
+		}); err != nil {
+			return resealed, err
+		}
+		resealed = resealed || sealedAny
+		if !more {
+			return resealed, nil
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package kv
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+var masterKey = bytes.Repeat([]byte{7}, 32)
+
+// rawContains reports whether any stored key or value, system keys included, contains needle.
+func rawContains(t *testing.T, s *Store, needle []byte) bool {
+	t.Helper()
+	found := false
+	if err := s.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			if err := it.Item().Value(func(val []byte) error {
+				found = found || bytes.Contains(val, needle)
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return found
+}
+
+func TestSeal(t *testing.T) {
+	dir := t.TempDir()
+	opts := Options{Path: dir, MasterKey: masterKey, SealedPrefixes: []string{"token:"}, Envelope: true}
+	s, err := Open(opts)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	secret := "sk-live-0123456789"
+	if err = s.Set("token:api", secret); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Set("public", "visible-value"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Incr("token:uses", 3); err != nil {
+		t.Fatal(err)
+	}
+	body := strings.Repeat("stream-secret ", 100)
+	if err = s.SetStream("token:blob", strings.NewReader(body)); err != nil {
+		t.Fatal(err)
+	}
+
+	if rawContains(t, s, []byte(secret)) || rawContains(t, s, []byte("stream-secret")) {
+		t.Error("sealed values are stored in plaintext")
+	}
+	if !rawContains(t, s, []byte("visible-value")) {
+		t.Error("values outside sealed prefixes should not be encrypted")
+	}
+	if _, meta := storedSize(t, s, "token:api"); meta&metaSealed == 0 {
+		t.Errorf("meta = %b, want metaSealed", meta)
+	}
+	if _, err = s.Counter("token:hits"); err == nil {
+		t.Error("Counter() under a sealed prefix should fail")
+	}
+	if _, err = s.TrainDictionary("token:", 0); err == nil {
+		t.Error("TrainDictionary() on a sealed prefix should fail")
+	}
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	wrong := opts
+	wrong.MasterKey = bytes.Repeat([]byte{8}, 32)
+	if _, err = Open(wrong); !errors.Is(err, ErrWrongMasterKey) {
+		t.Errorf("Open() with a wrong master key error = %v, want ErrWrongMasterKey", err)
+	}
+
+	if s, err = Open(opts); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+	var got string
+	if ok, err := s.Get("token:api", &got); err != nil || !ok || got != secret {
+		t.Errorf("Get() = %q, %v, %v, want %q", got, ok, err, secret)
+	}
+	if n, err := s.Incr("token:uses", 1); err != nil || n != 4 {
+		t.Errorf("Incr() = %d, %v, want 4", n, err)
+	}
+	r, err := s.GetStream("token:blob")
+	if err != nil {
+		t.Fatalf("GetStream() error = %v", err)
+	}
+	data, err := io.ReadAll(r)
+	_ = r.Close()
+	if err != nil || string(data) != body {
+		t.Errorf("GetStream() read %d bytes, %v, want %d", len(data), err, len(body))
+	}
+}
+
+func TestSeal_MovedValue(t *testing.T) {
+	s, err := Open(Options{MasterKey: masterKey, SealedPrefixes: []string{"token:"}})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+	if err = s.Set("token:a", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A sealed value copied under another key must not decrypt.
+	from, _ := serializeKey("token:a")
+	to, _ := serializeKey("token:b")
+	if err = s.db.Update(func(txn *badger.Txn) error {
+		item, err := txn.Get(from)
+		if err != nil {
+			return err
+		}
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		return txn.SetEntry(badger.NewEntry(to, val).WithMeta(item.UserMeta()))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	var got string
+	if _, err = s.Get("token:b", &got); !errors.Is(err, ErrCorruptValue) {
+		t.Errorf("Get() of a moved value error = %v, want ErrCorruptValue", err)
+	}
+}
+
+func TestSeal_RequiresMasterKey(t *testing.T) {
+	if _, err := Open(Options{SealedPrefixes: []string{"token:"}}); err == nil {
+		t.Error("Open() with SealedPrefixes and no MasterKey should fail")
+	}
+}
+
+func TestReseal(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(Options{Path: dir, StreamChunkSize: 8})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if err = s.Set("token:plain", "plain-secret", time.Hour.Milliseconds()); err != nil {
+		t.Fatal(err)
+	}
+	enveloped := s.WithCodec(HybridCodec)
+	enveloped.opts.Envelope = true
+	if err = enveloped.Set("token:env", "envelope-secret"); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.SetStream("token:blob", strings.NewReader(strings.Repeat("stream-secret ", 10))); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Set("public", "visible"); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Adding the prefix later leaves the existing values in plaintext until Reseal runs.
+	if s, err = Open(Options{Path: dir, MasterKey: masterKey, SealedPrefixes: []string{"token:"}}); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+	if !rawContains(t, s, []byte("plain-secret")) {
+		t.Fatal("expected the old value to be stored in plaintext before Reseal")
+	}
+	n, err := s.Reseal()
+	if err != nil || n != 3 {
+		t.Fatalf("Reseal() = %d, %v, want 3", n, err)
+	}
+	for _, secret := range []string{"plain-secret", "envelope-secret", "stream-secret"} {
+		if rawContains(t, s, []byte(secret)) {
+			t.Errorf("%s is still stored in plaintext after Reseal", secret)
+		}
+	}
+	if n, err = s.Reseal(); err != nil || n != 0 {
+		t.Errorf("second Reseal() = %d, %v, want 0", n, err)
+	}
+	// Older plaintext versions must not reach a backup taken after Reseal.
+	var backup bytes.Buffer
+	if _, err = s.db.Backup(&backup, 0); err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"plain-secret", "envelope-secret", "stream-secret"} {
+		if bytes.Contains(backup.Bytes(), []byte(secret)) {
+			t.Errorf("backup after Reseal contains %s in plaintext", secret)
+		}
+	}
+
+	for key, want := range map[string]string{"token:plain": "plain-secret", "token:env": "envelope-secret", "public": "visible"} {
+		var got string
+		if _, err = s.Get(key, &got); err != nil || got != want {
+			t.Errorf("Get(%q) = %q, %v, want %q", key, got, err, want)
+		}
+	}
+	if ttl, _, _ := s.TTL("token:plain"); ttl <= 0 {
+		t.Errorf("TTL() = %v, Reseal should keep the expiry", ttl)
+	}
+	rc, err := s.GetStream("token:blob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = rc.Close() }()
+	if body, err := io.ReadAll(rc); err != nil || string(body) != strings.Repeat("stream-secret ", 10) {
+		t.Errorf("GetStream() = %q, %v", body, err)
+	}
+}
+
+// A read-only store has no data key for a prefix that was never sealed; writes must fail cleanly.
+func TestSeal_ReadOnlyMissingKey(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(Options{Path: dir})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if err = s.Set("public", "v"); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ro, err := Open(Options{Path: dir, ReadOnly: true, MasterKey: masterKey, SealedPrefixes: []string{"token:"}})
+	if err != nil {
+		t.Fatalf("Open(ReadOnly) error = %v", err)
+	}
+	defer func() { _ = ro.Close() }()
+	if err = ro.Set("token:new", "secret"); err == nil {
+		t.Error("Set() under a prefix without a data key should fail")
+	}
+	if _, _, err = ro.seal([]byte("token:new"), nil, []byte("secret")); err == nil {
+		t.Error("seal() without a data key should fail")
+	}
+}
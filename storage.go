@@ -2,10 +2,9 @@ package kv
 
 import (
 	"fmt"
+	"reflect"
 
 	"github.com/pkg/errors"
-
-	"golang.org/x/sync/singleflight"
 )
 
 /*
@@ -15,80 +14,108 @@ Storage 函数提供了一个并发安全的缓存机制。
 它主要用于缓存那些取值成本高或取值不幂等的函数结果。
 */
 
-var sf singleflight.Group
-
-// Storage 是一个泛型函数，用于从缓存中获取或存储数据。
-// 如果键存在，则直接返回缓存中的值。
-// 如果键不存在，则调用 fn 函数生成值，存入缓存后再返回。
-// K, V 是泛型参数，代表任意类型的键和值。
+// Storage 从缓存中获取数据并解码到 value 指向的变量，如果键不存在则调用 fn 生成并存储。
 // key: 缓存键。
-// fn: 一个函数，当缓存未命中时调用，用于生成值。
+// value: 接收结果的指针，不能为 nil。
+// fn: 一个函数，当缓存未命中时调用，用于生成值。它返回的值必须能赋值给 value 指向的类型。
 // ttl: 可选参数，用于控制生存时间 (TTL)。
 //   - ttl[0]: TTL 值，单位毫秒。
 //   - ttl[1]: 续期策略。如果为 1，则只在创建时设置 TTL；否则，每次获取时都续期。
 //
 // 返回值:
-// V: 获取或生成的值。
 // error: 操作中发生的任何错误。
-func Storage[K, V any](key K, fn func() (value V, err error), ttl ...int64) (V, error) {
+func (s *Store) Storage(key, value any, fn func() (any, error), ttl ...int64) error {
+	out := reflect.ValueOf(value)
+	if out.Kind() != reflect.Ptr || out.IsNil() {
+		return errors.New("value must be a non-nil pointer")
+	}
+	out = out.Elem()
+
 	// 检查是仅在创建时设置 TTL 还是每次都续期。
 	if len(ttl) == 2 && ttl[1] == 1 {
 		// 仅在创建时设置 TTL，获取时不续期。
-		value, exists, err := Get[K, V](key)
+		exists, err := s.Get(key, value)
 		if err != nil {
-			return value, err
+			return err
 		}
 		if exists {
-			return value, nil
+			return nil
 		}
 	} else {
 		// 每次获取时都续期。
-		value, exists, err := Get[K, V](key, ttl...)
+		exists, err := s.Get(key, value, ttl...)
 		if err != nil {
-			return value, err
+			return err
 		}
 		if exists {
-			return value, nil
+			return nil
 		}
 	}
 
 	// 使用 singleflight 来确保 fn 函数在同一时间内只对同一个键执行一次。
-	// Do 方法的 key 是通过对泛型 key 进行格式化生成的字符串。
-	result, err, _ := sf.Do(fmt.Sprintf("%#v", key), func() (any, error) {
+	// Do 方法的 key 是通过对 key 进行格式化生成的字符串。
+	result, err, _ := s.sf.Do(fmt.Sprintf("%#v", key), func() (any, error) {
 		// 在 singleflight 内部再次检查缓存，因为在等待 Do 方法执行期间，
 		// 可能已有其他 goroutine 完成了值的计算和存储。
-		v, es, err := Get[K, V](key, ttl...)
+		v := reflect.New(out.Type())
+		es, err := s.Get(key, v.Interface(), ttl...)
 		if err != nil {
 			return nil, err
 		}
 		if es {
-			return v, nil
+			return v.Elem().Interface(), nil
 		}
 
 		// 如果缓存仍然未命中，则执行昂贵的 fn 函数来生成值。
-		v, err = fn()
+		result, err := fn()
 		if err != nil {
 			return nil, err
 		}
 
 		// 将生成的值存入缓存。
-		if err = Set[K, V](key, v, ttl...); err != nil {
+		if err = s.Set(key, result, ttl...); err != nil {
 			return nil, err
 		}
-		return v, nil
+		return result, nil
 	})
-	var value V
 	if err != nil {
-		return value, err
+		return err
 	}
 	if result == nil {
-		return value, nil
+		out.SetZero()
+		return nil
 	}
 
-	// 将 singleflight 返回的 any 类型结果断言为具体的类型 V。
-	value, ok := result.(V)
-	if !ok {
-		return value, errors.New("type assertion failed")
+	// 将 singleflight 返回的 any 类型结果赋值给 value 指向的变量。
+	rv := reflect.ValueOf(result)
+	if !rv.Type().AssignableTo(out.Type()) {
+		return errors.New("type assertion failed")
+	}
+	out.Set(rv)
+	return nil
+}
+
+// Storage 是一个泛型函数，用于从默认 Store 中获取或存储数据。
+// 如果键存在，则直接返回缓存中的值。
+// 如果键不存在，则调用 fn 函数生成值，存入缓存后再返回。
+// K, V 是泛型参数，代表任意类型的键和值。
+// key: 缓存键。
+// fn: 一个函数，当缓存未命中时调用，用于生成值。
+// ttl: 可选参数，用于控制生存时间 (TTL)。
+//   - ttl[0]: TTL 值，单位毫秒。
+//   - ttl[1]: 续期策略。如果为 1，则只在创建时设置 TTL；否则，每次获取时都续期。
+//
+// 返回值:
+// V: 获取或生成的值。
+// error: 操作中发生的任何错误。
+func Storage[K, V any](key K, fn func() (value V, err error), ttl ...int64) (V, error) {
+	var value V
+	s, err := defaultStore()
+	if err != nil {
+		return value, err
 	}
-	return value, nil
+	err = s.Storage(key, &value, func() (any, error) {
+		return fn()
+	}, ttl...)
+	return value, err
 }
@@ -0,0 +1,168 @@
+package kv
+
+import (
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"golang.org/x/sync/singleflight"
+)
+
+// Options 是打开一个 Store 时使用的配置。
+type Options struct {
+	// Path 是数据库目录。如果为空字符串，则使用内存模式。
+	Path string
+	// Codec 是值的编解码器，为 nil 时使用 HybridCodec。
+	Codec Codec
+	// Envelope 为 true 时在写入的值前加上记录编解码器和类型指纹的信封头部，
+	// 读取时类型不一致会返回 ErrTypeMismatch。读取总是能识别带信封和不带信封的值。
+	Envelope bool
+	// StrictDecode 为 true 时，HybridCodec 和 GobCodec 严格校验读取的值：
+	// 定长类型要求长度完全一致，gob 要求消费全部数据，不符合时返回 ErrCorruptValue。
+	StrictDecode bool
+	// Compression 是值的压缩算法，默认不压缩。
+	Compression Compression
+	// CompressionPrefixes 按键的前缀指定压缩算法，覆盖 Compression，最长的匹配前缀优先。
+	// 前缀是编码后的键的前缀，字符串键的编码就是其本身，Tuple 键可以使用 Tuple.Pack 的结果。
+	CompressionPrefixes map[string]Compression
+	// CompressionThreshold 是压缩的最小值大小，单位字节，更小的值按原样存储。
+	// 不大于 0 时使用 defaultCompressionThreshold。
+	CompressionThreshold int
+	// StreamChunkSize 是 SetStream 切分数据的块大小，单位字节。不大于 0 时使用 defaultStreamChunkSize。
+	StreamChunkSize int
+	// StreamCollectInterval 是在后台执行 CollectStreams 的间隔，为 0 时使用 defaultStreamCollectInterval，
+	// 为负数时只在 Open 时执行。只读或关闭了冲突检测时不在后台执行。
+	StreamCollectInterval time.Duration
+	// MigrateOnRead 为 true 时，Get 读到旧 schema 版本的值并完成迁移后，将当前版本的值写回数据库。
+	// 参见 RegisterMigration。
+	MigrateOnRead bool
+	// EncryptionKey 是静态加密的 AES 密钥，长度为 16、24 或 32 字节，为空时不加密。
+	// 已加密的目录必须使用同一个密钥打开，参见 RotateEncryptionKey。
+	EncryptionKey []byte
+	// IndexCacheSize 是 Badger 索引缓存的大小，单位字节。
+	// 不大于 0 时，启用加密则使用 defaultIndexCacheSize，否则所有索引常驻内存。
+	IndexCacheSize int64
+	// MasterKey 是加密各前缀数据密钥的 AES 密钥，长度为 16、24 或 32 字节。
+	// 打开时会校验数据库中已有的数据密钥，主密钥不正确时返回 ErrWrongMasterKey。
+	MasterKey []byte
+	// SealedPrefixes 是需要密封的键前缀，这些前缀下的值用各自的数据密钥以 AES-GCM 加密后存储，
+	// 读取时透明解密。设置时 MasterKey 不能为空。前缀的编码方式与 CompressionPrefixes 相同。
+	// 只有之后写入的值会被密封，加入新的前缀后需要调用 Store.Reseal 密封该前缀下已有的值。
+	// 与已训练字典的前缀重叠时 Open 返回错误，因为字典以明文保存了训练样本的片段。
+	SealedPrefixes []string
+	// 以下是 Badger 的调优选项，零值表示使用 Badger 的默认值，对应的配置项见 tuning.go。
+
+	// SyncWrites 为 true 时每次写入后同步到磁盘。
+	SyncWrites bool
+	// MemTableSize 是内存表的大小，单位字节。
+	MemTableSize int64
+	// ValueLogFileSize 是单个值日志文件的大小，单位字节，范围为 [1MB, 2GB)。
+	ValueLogFileSize int64
+	// ValueThreshold 是写入值日志的最小值大小，单位字节，不能超过 1MB，也不能超过 MemTableSize 的 15%。
+	ValueThreshold int64
+	// BlockCacheSize 是块缓存的大小，单位字节。
+	BlockCacheSize int64
+	// TableCompression 是 SST 的压缩算法，取值为 "none"、"snappy" 或 "zstd"。
+	// 它与压缩单个值的 Compression 相互独立。
+	TableCompression string
+	// NumCompactors 是压缩 (compaction) 协程数，不能为 1。
+	NumCompactors int
+	// ReadOnly 为 true 时以只读方式打开数据库目录，此时 Path 不能为空，所有写入都会失败。
+	ReadOnly bool
+	// DisableConflictDetection 为 true 时读写事务不检测冲突，写入更快，但并发的读改写可能丢失更新。
+	DisableConflictDetection bool
+	// ConflictRetries 是读写事务遇到 badger.ErrConflict 时的最大重试次数。
+	// 为 0 时使用 defaultConflictRetries，为负数时不重试。
+	ConflictRetries int
+}
+
+// defaultConflictRetries 是 Options.ConflictRetries 为 0 时使用的重试次数。
+const defaultConflictRetries = 3
+
+// Store 是一个独立的 Badger 数据库实例。
+// 同一进程中可以同时打开多个 Store，例如按租户划分的缓存，或者一个持久化存储加一个内存存储。
+// 包级别的 Set、Get 等函数作用于默认的 Store。
+type Store struct {
+	db    *badger.DB
+	opts  Options
+	codec Codec
+	// sf 用于 Storage 方法，确保同一个键的取值函数在同一时间只执行一次。
+	sf *singleflight.Group
+	// uploads 记录正在进行的 SetStream 的流 ID。
+	uploads *sync.Map
+	// dicts 是 TrainDictionary 训练并保存在数据库中的压缩字典。
+	dicts *dictionaries
+	// sealKeys 是各密封前缀的数据密钥，打开后不再改变。
+	sealKeys map[string]dataKey
+	// collector 在后台定期执行 CollectStreams，没有启用时为 nil。
+	collector *streamCollector
+}
+
+// Open 按照 opts 打开一个新的 Store。
+// 与包初始化时的默认 Store 不同，打开失败时返回错误，由调用者自行处理。
+func Open(opts Options) (*Store, error) {
+	if err := checkCompression(opts); err != nil {
+		return nil, err
+	}
+	opt, err := badgerOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	db, err := badger.Open(opt)
+	if err != nil {
+		return nil, encryptionError(opts.Path, opts.EncryptionKey, err)
+	}
+	if opts.ConflictRetries == 0 {
+		opts.ConflictRetries = defaultConflictRetries
+	}
+	codec := opts.Codec
+	if codec == nil {
+		codec = HybridCodec
+	}
+	s := &Store{db: db, opts: opts, sf: &singleflight.Group{}, uploads: &sync.Map{}, dicts: newDictionaries()}
+	s.codec = s.strictCodec(codec)
+	// 清理上次运行中未完成的上传和不再被引用的流。只读时无法删除，留到下次读写打开时。
+	if !opts.ReadOnly {
+		if _, err = s.CollectStreams(); err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+	}
+	if err = s.loadDictionaries(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if err = s.loadSealKeys(); err != nil {
+		_ = db.Close()
+		s.dicts.reset()
+		return nil, err
+	}
+	if interval := opts.StreamCollectInterval; interval >= 0 && !opts.ReadOnly && !opts.DisableConflictDetection {
+		if interval == 0 {
+			interval = defaultStreamCollectInterval
+		}
+		s.collector = s.startStreamCollector(interval)
+	}
+	return s, nil
+}
+
+// Drop 清空整个数据库。
+func (s *Store) Drop() error {
+	if err := s.db.DropAll(); err != nil {
+		return err
+	}
+	// 字典随数据库一起被清空，之后的写入不再使用字典压缩。
+	s.dicts.reset()
+	// 数据密钥需要保留，否则之后密封的值在重新打开后无法解密。
+	return s.storeSealKeys()
+}
+
+// Close 关闭数据库连接。
+func (s *Store) Close() error {
+	if s.collector != nil {
+		s.collector.stop()
+	}
+	err := s.db.Close()
+	s.dicts.reset()
+	return err
+}
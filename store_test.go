@@ -0,0 +1,52 @@
+package kv
+
+import (
+	"testing"
+)
+
+func TestOpen_Independent(t *testing.T) {
+	a, err := Open(Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = a.Close() }()
+	b, err := Open(Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = b.Close() }()
+
+	if err = a.Set("store_key", "store_value"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	var got string
+	exists, err := a.Get("store_key", &got)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if !exists || got != "store_value" {
+		t.Errorf("Get() = %v, %v, want store_value, true", got, exists)
+	}
+
+	exists, err = b.Get("store_key", &got)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if exists {
+		t.Errorf("Get() key should not exist in another store")
+	}
+}
+
+func TestStore_GetNonPointer(t *testing.T) {
+	s, err := Open(Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	var got string
+	if _, err = s.Get("store_key", got); err == nil {
+		t.Errorf("Get() with non-pointer value should return error")
+	}
+}
@@ -0,0 +1,481 @@
+package kv
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/pkg/errors"
+)
+
+/*
+流式值用于缓存无法一次放入内存的大对象。SetStream 将数据切分为固定大小的块，
+写在保留的系统键空间中，最后在一个事务中把清单 (manifest) 写到用户的键上，因此写入是原子的：
+读取者要么看到旧值，要么看到完整的新值。
+
+键的布局：
+  - 用户的键：清单，UserMeta 设置 metaStream 位。内容为 流 ID(16) + 总字节数(8) + 块数(4) + 块大小(4)。
+  - systemPrefix + "s/" + ID：流的所有者记录，值为用户的键。在写入块之前创建。
+  - systemPrefix + "c/" + ID + 块序号(4)：数据块。
+
+清单被覆盖、删除或过期后，或者上传中途失败、进程退出后，块就成了孤儿。
+CollectStreams 根据所有者记录找出不再被清单引用的流并删除其块，Open 时会自动执行一次，
+之后按 Options.StreamCollectInterval 在后台定期执行。
+
+CollectStreams 先在快照中找出候选的流，再对每个流在读写事务中重新确认它没有正在进行的上传、
+也不再被清单引用，然后清空其所有者记录，表示流已确认为孤儿，最后删除块和所有者记录。
+与确认同时提交清单的 SetStream 会使这个事务冲突，流因此不会被误删；
+所以关闭冲突检测 (Options.DisableConflictDetection) 时不会在后台执行，
+手动调用 CollectStreams 也需要避开并发的 SetStream。删除中断时，所有者记录为空的流在下次执行时继续删除。
+*/
+
+// metaStream 是 UserMeta 中表示值是流式值清单的位。
+const metaStream byte = 1 << 2
+
+// systemPrefix 是 kv 内部使用的保留键前缀，Scan 和 Range 不会返回以它开头的键。
+var systemPrefix = []byte("\xff\xfekv.sys/")
+
+// defaultStreamChunkSize 是 Options.StreamChunkSize 不大于 0 时使用的块大小。
+const defaultStreamChunkSize = 1 << 20
+
+// defaultStreamCollectInterval 是 Options.StreamCollectInterval 为 0 时使用的间隔。
+const defaultStreamCollectInterval = 10 * time.Minute
+
+const (
+	// streamIDSize 是流 ID 的字节数。
+	streamIDSize = 16
+	// manifestSize 是清单的字节数。
+	manifestSize = streamIDSize + 16
+)
+
+// ErrStreamValue 表示键的值是由 SetStream 写入的流式值，需要使用 GetStream 读取。
+var ErrStreamValue = errors.New("value is a stream")
+
+// manifest 是流式值的清单。
+type manifest struct {
+	id        []byte
+	size      uint64
+	chunks    uint32
+	chunkSize uint32
+}
+
+func (m manifest) encode() []byte {
+	buf := make([]byte, 0, manifestSize)
+	buf = append(buf, m.id...)
+	buf = binary.BigEndian.AppendUint64(buf, m.size)
+	buf = binary.BigEndian.AppendUint32(buf, m.chunks)
+	return binary.BigEndian.AppendUint32(buf, m.chunkSize)
+}
+
+func decodeManifest(data []byte) (manifest, error) {
+	if len(data) != manifestSize {
+		return manifest{}, errors.New("invalid stream manifest")
+	}
+	return manifest{
+		id:        bytes.Clone(data[:streamIDSize]),
+		size:      binary.BigEndian.Uint64(data[streamIDSize:]),
+		chunks:    binary.BigEndian.Uint32(data[streamIDSize+8:]),
+		chunkSize: binary.BigEndian.Uint32(data[streamIDSize+12:]),
+	}, nil
+}
+
+// streamOwnerKey 返回流 ID 的所有者记录的键。
+func streamOwnerKey(id []byte) []byte {
+	return append(append(bytes.Clone(systemPrefix), "s/"...), id...)
+}
+
+// streamChunkKey 返回流 ID 的第 i 个块的键。
+func streamChunkKey(id []byte, i uint32) []byte {
+	k := append(append(bytes.Clone(systemPrefix), "c/"...), id...)
+	return binary.BigEndian.AppendUint32(k, i)
+}
+
+// SetStream 从 r 读取数据，分块存入默认 Store 的键下，可选择性地设置生存时间 (TTL)。
+// 写入是原子的；r 返回错误时，键保持原来的值，已写入的块会被删除。
+// K 是泛型参数，代表任意类型的键。
+// key: 键。
+// r: 数据来源。
+// ttl: 可选参数，生命周期，单位毫秒。
+func SetStream[K any](key K, r io.Reader, ttl ...int64) error {
+	s, err := defaultStore()
+	if err != nil {
+		return err
+	}
+	return s.SetStream(key, r, ttl...)
+}
+
+// GetStream 返回读取默认 Store 中键的流式值的 io.ReadCloser。
+// 读取器在一个只读事务的快照上工作，读取期间键被覆盖也不受影响，使用完后必须调用 Close。
+// 对于由 Set 写入的值，返回其原始编码（含义同 ViewBytes）的读取器。
+// K 是泛型参数，代表任意类型的键。
+// key: 键。
+// 返回值:
+// io.ReadCloser: 值的读取器。
+// error: 键不存在时返回 badger.ErrKeyNotFound，以及操作中发生的任何其他错误。
+func GetStream[K any](key K) (io.ReadCloser, error) {
+	s, err := defaultStore()
+	if err != nil {
+		return nil, err
+	}
+	return s.GetStream(key)
+}
+
+// CollectStreams 删除默认 Store 中不再被任何清单引用的流式值的块，
+// 包括被覆盖、删除、过期的流，以及中途失败的上传。
+// 返回值:
+// int: 被删除的流的数量。
+// error: 操作中发生的任何错误。
+func CollectStreams() (int, error) {
+	s, err := defaultStore()
+	if err != nil {
+		return 0, err
+	}
+	return s.CollectStreams()
+}
+
+// SetStream 从 r 读取数据并分块写入，语义与包级别的 SetStream 相同。
+func (s *Store) SetStream(key any, r io.Reader, ttl ...int64) error {
+	k, err := serializeKey(key)
+	if err != nil {
+		return err
+	}
+	id := make([]byte, streamIDSize)
+	if _, err = rand.Read(id); err != nil {
+		return err
+	}
+
+	// 上传期间记录流 ID，避免被并发的 CollectStreams 当作孤儿删除。
+	s.uploads.Store(string(id), struct{}{})
+	defer s.uploads.Delete(string(id))
+
+	m, err := s.writeChunks(k, id, r)
+	if err != nil {
+		// 尽力清理已写入的块，失败时留给 CollectStreams。
+		_ = s.deleteStream(id)
+		return err
+	}
+
+	var old []byte
+	err = s.update(func(txn *badger.Txn) error {
+		old = nil
+		if item, err := txn.Get(k); err == nil && item.UserMeta()&metaStream != 0 {
+			if err = item.Value(func(val []byte) error {
+				prev, err := decodeManifest(val)
+				old = prev.id
+				return err
+			}); err != nil {
+				return err
+			}
+		} else if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+		return txn.SetEntry(newEntry(k, m.encode(), metaStream, ttl))
+	})
+	if err != nil {
+		_ = s.deleteStream(id)
+		return err
+	}
+	if old != nil {
+		// 旧流已不再被引用，删除失败时留给 CollectStreams。
+		_ = s.deleteStream(old)
+	}
+	return nil
+}
+
+// writeChunks 写入所有者记录，然后从 r 读取数据并逐块写入，返回新流的清单。
+func (s *Store) writeChunks(k, id []byte, r io.Reader) (manifest, error) {
+	m := manifest{id: id, chunkSize: defaultStreamChunkSize}
+	if s.opts.StreamChunkSize > 0 {
+		m.chunkSize = uint32(s.opts.StreamChunkSize)
+	}
+	if err := s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(streamOwnerKey(id), k)
+	}); err != nil {
+		return m, err
+	}
+
+	wb := s.db.NewWriteBatch()
+	defer wb.Cancel()
+	for {
+		// WriteBatch 在 Flush 之前持有数据，因此每个块使用新的缓冲区。
+		chunk := make([]byte, m.chunkSize)
+		n, err := io.ReadFull(r, chunk)
+		if n > 0 {
+			ck := streamChunkKey(id, m.chunks)
+			data, sealed, err := s.seal(k, ck, chunk[:n])
+			if err != nil {
+				return m, err
+			}
+			var meta byte
+			if sealed {
+				meta = metaSealed
+			}
+			if err := wb.SetEntry(badger.NewEntry(ck, data).WithMeta(meta)); err != nil {
+				return m, err
+			}
+			m.chunks++
+			m.size += uint64(n)
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return m, err
+		}
+	}
+	return m, wb.Flush()
+}
+
+// deleteStream 删除流 ID 的所有块和所有者记录。
+func (s *Store) deleteStream(id []byte) error {
+	prefix := append(append(bytes.Clone(systemPrefix), "c/"...), id...)
+	wb := s.db.NewWriteBatch()
+	defer wb.Cancel()
+	if err := s.scan(prefix, ScanOptions{KeyOnly: true}, func(item *badger.Item) (bool, error) {
+		return true, wb.Delete(item.KeyCopy(nil))
+	}); err != nil {
+		return err
+	}
+	if err := wb.Delete(streamOwnerKey(id)); err != nil {
+		return err
+	}
+	return wb.Flush()
+}
+
+// GetStream 返回读取键的值的 io.ReadCloser，语义与包级别的 GetStream 相同。
+func (s *Store) GetStream(key any) (io.ReadCloser, error) {
+	k, err := serializeKey(key)
+	if err != nil {
+		return nil, err
+	}
+	txn := s.db.NewTransaction(false)
+	item, err := txn.Get(k)
+	if err != nil {
+		txn.Discard()
+		return nil, err
+	}
+	if item.UserMeta()&metaStream == 0 {
+		// 普通的值直接返回其原始编码。
+		defer txn.Discard()
+		var data []byte
+		if err = item.Value(func(val []byte) error {
+			raw, err := s.payload(k, val, item.UserMeta())
+			data = bytes.Clone(raw)
+			return err
+		}); err != nil {
+			return nil, corrupt(item, err)
+		}
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+
+	var m manifest
+	if err = item.Value(func(val []byte) error {
+		m, err = decodeManifest(val)
+		return err
+	}); err != nil {
+		txn.Discard()
+		return nil, corrupt(item, err)
+	}
+	return &streamReader{s: s, k: k, txn: txn, m: m}, nil
+}
+
+// streamReader 在只读事务中按顺序读取流的块。
+type streamReader struct {
+	s *Store
+	// k 是清单所在的键，用于解密密封的块。
+	k   []byte
+	txn *badger.Txn
+	m   manifest
+	// next 是下一个要读取的块的序号，buf 是当前块中尚未读取的部分。
+	next uint32
+	buf  []byte
+}
+
+func (r *streamReader) Read(p []byte) (int, error) {
+	if r.txn == nil {
+		return 0, errors.New("read on closed stream")
+	}
+	for len(r.buf) == 0 {
+		if r.next >= r.m.chunks {
+			return 0, io.EOF
+		}
+		ck := streamChunkKey(r.m.id, r.next)
+		item, err := r.txn.Get(ck)
+		if err != nil {
+			return 0, errors.Wrapf(err, "read chunk %d", r.next)
+		}
+		if r.buf, err = item.ValueCopy(nil); err != nil {
+			return 0, err
+		}
+		if item.UserMeta()&metaSealed != 0 {
+			if r.buf, err = r.s.unseal(r.k, ck, r.buf); err != nil {
+				return 0, errors.Wrapf(err, "read chunk %d", r.next)
+			}
+		}
+		r.next++
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func (r *streamReader) Close() error {
+	if r.txn != nil {
+		r.txn.Discard()
+		r.txn = nil
+	}
+	return nil
+}
+
+// CollectStreams 删除不再被引用的流，语义与包级别的 CollectStreams 相同。
+func (s *Store) CollectStreams() (int, error) {
+	var candidates [][]byte
+	err := s.db.View(func(txn *badger.Txn) error {
+		return s.scanIn(txn, append(bytes.Clone(systemPrefix), "s/"...), func(item *badger.Item) error {
+			id := item.Key()[len(systemPrefix)+2:]
+			if _, ok := s.uploads.Load(string(id)); ok {
+				return nil
+			}
+			var owner []byte
+			if err := item.Value(func(val []byte) error {
+				owner = bytes.Clone(val)
+				return nil
+			}); err != nil {
+				return err
+			}
+			// 所有者记录为空表示上次已确认为孤儿，只是没有删除完。
+			if len(owner) > 0 {
+				if live, err := streamLive(txn, owner, id); err != nil || live {
+					return err
+				}
+			}
+			candidates = append(candidates, bytes.Clone(id))
+			return nil
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	for _, id := range candidates {
+		orphan, err := s.claimOrphan(id)
+		if err != nil {
+			return n, err
+		}
+		if !orphan {
+			continue
+		}
+		if err = s.deleteStream(id); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// claimOrphan 在读写事务中重新确认流 ID 没有正在进行的上传，也不再被清单引用，然后清空其所有者记录。
+// 返回值表示流是否已确认为孤儿，可以删除。与此同时提交清单的事务使确认冲突时返回 false。
+func (s *Store) claimOrphan(id []byte) (bool, error) {
+	var orphan bool
+	err := s.db.Update(func(txn *badger.Txn) error {
+		orphan = false
+		if _, ok := s.uploads.Load(string(id)); ok {
+			return nil
+		}
+		item, err := txn.Get(streamOwnerKey(id))
+		if err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				// 已被其他调用删除。
+				return nil
+			}
+			return err
+		}
+		owner, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		if len(owner) > 0 {
+			live, err := streamLive(txn, owner, id)
+			if err != nil || live {
+				return err
+			}
+			if err = txn.Set(streamOwnerKey(id), []byte{}); err != nil {
+				return err
+			}
+		}
+		orphan = true
+		return nil
+	})
+	if errors.Is(err, badger.ErrConflict) {
+		return false, nil
+	}
+	return orphan, err
+}
+
+// streamCollector 在后台定期执行 CollectStreams，直到 stop 被调用。
+type streamCollector struct {
+	quit chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// startStreamCollector 启动后台的 CollectStreams 循环，出错的那一次跳过，留到下一次执行。
+func (s *Store) startStreamCollector(interval time.Duration) *streamCollector {
+	c := &streamCollector{quit: make(chan struct{}), done: make(chan struct{})}
+	go func() {
+		defer close(c.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.quit:
+				return
+			case <-ticker.C:
+				_, _ = s.CollectStreams()
+			}
+		}
+	}()
+	return c
+}
+
+// stop 停止后台循环并等待正在进行的 CollectStreams 结束，可以重复调用。
+func (c *streamCollector) stop() {
+	c.once.Do(func() { close(c.quit) })
+	<-c.done
+}
+
+// streamLive 判断所有者键 owner 上的清单是否仍然引用流 ID。
+func streamLive(txn *badger.Txn, owner, id []byte) (bool, error) {
+	item, err := txn.Get(owner)
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	if item.UserMeta()&metaStream == 0 {
+		return false, nil
+	}
+	var live bool
+	err = item.Value(func(val []byte) error {
+		live = len(val) >= streamIDSize && bytes.Equal(val[:streamIDSize], id)
+		return nil
+	})
+	return live, err
+}
+
+// scanIn 在已有的事务中遍历所有以 prefix 开头的条目。
+func (s *Store) scanIn(txn *badger.Txn, prefix []byte, fn func(item *badger.Item) error) error {
+	it := txn.NewIterator(badger.IteratorOptions{Prefix: prefix})
+	defer it.Close()
+	for it.Seek(prefix); it.Valid(); it.Next() {
+		if err := fn(it.Item()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+package kv
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// chunkCount returns the number of stream chunks stored in s.
+func chunkCount(t *testing.T, s *Store) int {
+	t.Helper()
+	n := 0
+	if err := s.scan(append(bytes.Clone(systemPrefix), "c/"...), ScanOptions{KeyOnly: true}, func(*badger.Item) (bool, error) {
+		n++
+		return true, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func readStream(t *testing.T, s *Store, key string) []byte {
+	t.Helper()
+	rc, err := s.GetStream(key)
+	if err != nil {
+		t.Fatalf("GetStream() error = %v", err)
+	}
+	defer func() { _ = rc.Close() }()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("read stream: %v", err)
+	}
+	return data
+}
+
+// failingReader returns n bytes and then an error.
+type failingReader struct{ n int }
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.n == 0 {
+		return 0, errors.New("connection reset")
+	}
+	n := min(len(p), r.n)
+	r.n -= n
+	return n, nil
+}
+
+func TestStream_RoundTrip(t *testing.T) {
+	s, err := Open(Options{StreamChunkSize: 1000})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	data := bytes.Repeat([]byte("0123456789"), 1050)
+	if err = s.SetStream("artifact", bytes.NewReader(data)); err != nil {
+		t.Fatalf("SetStream() error = %v", err)
+	}
+	if got := readStream(t, s, "artifact"); !bytes.Equal(got, data) {
+		t.Errorf("GetStream() returned %d bytes, want %d", len(got), len(data))
+	}
+	if n := chunkCount(t, s); n != 11 {
+		t.Errorf("chunk count = %d, want 11", n)
+	}
+
+	var v []byte
+	if _, err = s.Get("artifact", &v); !errors.Is(err, ErrStreamValue) {
+		t.Errorf("Get() error = %v, want ErrStreamValue", err)
+	}
+
+	// System keys are invisible to Scan.
+	seq, errf := ScanStore[string, []byte](s, "", ScanOptions{KeyOnly: true})
+	n := 0
+	for range seq {
+		n++
+	}
+	if errf() != nil || n != 1 {
+		t.Errorf("Scan() found %d keys, err = %v, want 1", n, errf())
+	}
+}
+
+func TestStream_Overwrite(t *testing.T) {
+	s, err := Open(Options{StreamChunkSize: 4})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	if err = s.SetStream("k", bytes.NewReader([]byte("old value"))); err != nil {
+		t.Fatal(err)
+	}
+	reader, err := s.GetStream("k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	if err = s.SetStream("k", bytes.NewReader([]byte("new"))); err != nil {
+		t.Fatal(err)
+	}
+	if n := chunkCount(t, s); n != 1 {
+		t.Errorf("chunk count after overwrite = %d, want 1", n)
+	}
+	if got := readStream(t, s, "k"); string(got) != "new" {
+		t.Errorf("GetStream() = %q, want new", got)
+	}
+
+	// A reader opened before the overwrite keeps its snapshot.
+	old, err := io.ReadAll(reader)
+	if err != nil || string(old) != "old value" {
+		t.Errorf("old reader = %q, %v, want old value", old, err)
+	}
+}
+
+func TestStream_FailedUpload(t *testing.T) {
+	s, err := Open(Options{StreamChunkSize: 4})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	if err = s.SetStream("k", bytes.NewReader([]byte("keep"))); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.SetStream("k", &failingReader{n: 10}); err == nil {
+		t.Fatal("SetStream() should fail when the reader fails")
+	}
+	if got := readStream(t, s, "k"); string(got) != "keep" {
+		t.Errorf("GetStream() = %q, want the previous value", got)
+	}
+	if n := chunkCount(t, s); n != 1 {
+		t.Errorf("chunk count = %d, want 1", n)
+	}
+}
+
+func TestCollectStreams(t *testing.T) {
+	s, err := Open(Options{StreamChunkSize: 4})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	if err = s.SetStream("k", bytes.NewReader([]byte("some data"))); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.CollectStreams(); err != nil || n != 0 {
+		t.Errorf("CollectStreams() = %d, %v, want 0 for a live stream", n, err)
+	}
+	if err = s.Del("k"); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.CollectStreams(); err != nil || n != 1 {
+		t.Errorf("CollectStreams() = %d, %v, want 1", n, err)
+	}
+	if n := chunkCount(t, s); n != 0 {
+		t.Errorf("chunk count = %d, want 0", n)
+	}
+}
+
+// A stream that looked orphaned in the snapshot is re-checked before it is deleted.
+func TestCollectStreams_Recheck(t *testing.T) {
+	s, err := Open(Options{StreamChunkSize: 4, StreamCollectInterval: -1})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	if err = s.SetStream("k", bytes.NewReader([]byte("some data"))); err != nil {
+		t.Fatal(err)
+	}
+	k, _ := serializeKey("k")
+	var id []byte
+	if err = s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(k)
+		if err != nil {
+			return err
+		}
+		val, err := item.ValueCopy(nil)
+		id = val[:streamIDSize]
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := s.claimOrphan(id); err != nil || ok {
+		t.Errorf("claimOrphan() = %v, %v, want false for a live stream", ok, err)
+	}
+	if err = s.Del("k"); err != nil {
+		t.Fatal(err)
+	}
+	s.uploads.Store(string(id), struct{}{})
+	if ok, err := s.claimOrphan(id); err != nil || ok {
+		t.Errorf("claimOrphan() = %v, %v, want false during an upload", ok, err)
+	}
+	s.uploads.Delete(string(id))
+	if ok, err := s.claimOrphan(id); err != nil || !ok {
+		t.Errorf("claimOrphan() = %v, %v, want true", ok, err)
+	}
+	// A claimed stream whose chunks were not deleted is finished by the next run.
+	if n, err := s.CollectStreams(); err != nil || n != 1 || chunkCount(t, s) != 0 {
+		t.Errorf("CollectStreams() = %d, %v, want 1 and no chunks left", n, err)
+	}
+}
+
+func TestCollectStreams_Background(t *testing.T) {
+	s, err := Open(Options{StreamChunkSize: 4, StreamCollectInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	if err = s.SetStream("k", bytes.NewReader([]byte("some data"))); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Del("k"); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for chunkCount(t, s) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("chunks of a deleted stream were not collected in the background")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestGetStream_PlainValue(t *testing.T) {
+	s, err := Open(Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	if err = s.Set("body", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if got := readStream(t, s, "body"); string(got) != "hello" {
+		t.Errorf("GetStream() = %q, want hello", got)
+	}
+	if _, err = s.GetStream("missing"); !errors.Is(err, badger.ErrKeyNotFound) {
+		t.Errorf("GetStream() error = %v, want badger.ErrKeyNotFound", err)
+	}
+}
@@ -0,0 +1,64 @@
+package kv
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/pkg/errors"
+)
+
+// ErrCorruptValue 表示存储的值无法解码，例如长度与类型不符、数据被截断或带有多余的字节。
+// 具体的键可以通过 errors.As 取得 *CorruptValueError。
+var ErrCorruptValue = errors.New("corrupt value")
+
+// CorruptValueError 是值无法解码时返回的错误，记录了出错的键。
+// errors.Is(err, ErrCorruptValue) 对它返回 true。
+type CorruptValueError struct {
+	// Key 是出错的键编码后的字节，Tuple 键可以用 Unpack 还原。
+	Key []byte
+	// Err 是解码时的原始错误。
+	Err error
+}
+
+func (e *CorruptValueError) Error() string {
+	return fmt.Sprintf("corrupt value for key %q: %v", e.Key, e.Err)
+}
+
+// Unwrap 使 errors.Is 能同时匹配 ErrCorruptValue 和原始错误。
+func (e *CorruptValueError) Unwrap() []error {
+	return []error{ErrCorruptValue, e.Err}
+}
+
+// errInvalidLength 表示数据的长度与类型不符、被截断或带有多余的字节。
+// 编解码器返回的这类错误由 decodeError 包装为 *CorruptValueError。
+var errInvalidLength = errors.New("invalid data length")
+
+// decodeError 将解码条目的值时发生的长度错误（包括 gob 遇到的意外 EOF）包装为 *CorruptValueError，
+// 其他错误，例如 UnmarshalKV 或迁移函数返回的错误、类型不兼容，原样返回。
+func decodeError(item *badger.Item, err error) error {
+	if errors.Is(err, errInvalidLength) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return corrupt(item, err)
+	}
+	return err
+}
+
+// corrupt 将解码条目的值时发生的错误包装为 *CorruptValueError。
+func corrupt(item *badger.Item, err error) error {
+	return &CorruptValueError{Key: item.KeyCopy(nil), Err: err}
+}
+
+// strictCodec 在启用了 Options.StrictDecode 时返回内置编解码器的严格版本，其他编解码器原样返回。
+// 严格模式下，定长类型要求数据长度完全一致，gob 要求消费全部数据。
+func (s *Store) strictCodec(c Codec) Codec {
+	if !s.opts.StrictDecode {
+		return c
+	}
+	switch c.(type) {
+	case hybridCodec:
+		return hybridCodec{strict: true}
+	case gobCodec:
+		return gobCodec{strict: true}
+	}
+	return c
+}
@@ -0,0 +1,138 @@
+package kv
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+type strictRecord struct {
+	Name string
+}
+
+func putRaw(t *testing.T, s *Store, key string, raw []byte) {
+	t.Helper()
+	k, _ := serializeKey(key)
+	if err := s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(k, raw)
+	}); err != nil {
+		t.Fatalf("write raw value: %v", err)
+	}
+}
+
+func TestStrictDecode_Width(t *testing.T) {
+	lax, err := Open(Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = lax.Close() }()
+	strict, err := Open(Options{StrictDecode: true})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = strict.Close() }()
+
+	for _, s := range []*Store{lax, strict} {
+		if err = s.Set("n", int64(7)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var n32 int32
+	if _, err = lax.Get("n", &n32); err != nil {
+		t.Errorf("lax Get() error = %v, want nil", err)
+	}
+	_, err = strict.Get("n", &n32)
+	if !errors.Is(err, ErrCorruptValue) {
+		t.Fatalf("strict Get() error = %v, want ErrCorruptValue", err)
+	}
+	var cve *CorruptValueError
+	if !errors.As(err, &cve) {
+		t.Fatalf("strict Get() error should be a *CorruptValueError")
+	}
+	if k, _ := serializeKey("n"); !bytes.Equal(cve.Key, k) {
+		t.Errorf("CorruptValueError.Key = %q, want %q", cve.Key, k)
+	}
+
+	var n64 int64
+	if _, err = strict.Get("n", &n64); err != nil || n64 != 7 {
+		t.Errorf("strict Get() = %d, %v, want 7", n64, err)
+	}
+}
+
+func TestStrictDecode_Truncated(t *testing.T) {
+	s, err := Open(Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	// Truncated data is rejected even without strict mode.
+	putRaw(t, s, "short", []byte{1, 2, 3})
+	var n int64
+	if _, err = s.Get("short", &n); !errors.Is(err, ErrCorruptValue) {
+		t.Errorf("Get() error = %v, want ErrCorruptValue", err)
+	}
+}
+
+func TestStrictDecode_GobTrailing(t *testing.T) {
+	for _, strict := range []bool{false, true} {
+		s, err := Open(Options{StrictDecode: strict})
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+		data, err := serialize(strictRecord{Name: "x"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		putRaw(t, s, "rec", append(data, 0xFF, 0xFF))
+
+		var got strictRecord
+		_, err = s.Get("rec", &got)
+		if strict && !errors.Is(err, ErrCorruptValue) {
+			t.Errorf("strict Get() error = %v, want ErrCorruptValue", err)
+		}
+		if !strict && err != nil {
+			t.Errorf("lax Get() error = %v, want nil", err)
+		}
+		_ = s.Close()
+	}
+}
+
+var errRejected = errors.New("rejected")
+
+// rejectingValue fails every UnmarshalKV call with errRejected.
+type rejectingValue struct{}
+
+func (rejectingValue) MarshalKV() ([]byte, error) { return []byte{1}, nil }
+
+func (*rejectingValue) UnmarshalKV([]byte) error { return errRejected }
+
+func TestDecode_NonCorruptErrors(t *testing.T) {
+	s, err := Open(Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	// Errors from UnmarshalKV are passed through rather than reported as corruption.
+	if err = s.Set("rejecting", rejectingValue{}); err != nil {
+		t.Fatal(err)
+	}
+	var v rejectingValue
+	if _, err = s.Get("rejecting", &v); !errors.Is(err, errRejected) || errors.Is(err, ErrCorruptValue) {
+		t.Errorf("Get() error = %v, want errRejected without ErrCorruptValue", err)
+	}
+
+	// So are type errors from the codec.
+	view := s.WithCodec(JSONCodec)
+	if err = view.Set("json", "text"); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	if _, err = view.Get("json", &n); err == nil || errors.Is(err, ErrCorruptValue) {
+		t.Errorf("Get() error = %v, want a non-corrupt type error", err)
+	}
+}
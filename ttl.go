@@ -0,0 +1,177 @@
+package kv
+
+import (
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/pkg/errors"
+)
+
+// NoExpiry 是 TTL 对没有设置过期时间的键返回的剩余时间。
+const NoExpiry time.Duration = -1
+
+// TTL 返回默认 Store 中键的剩余生存时间，不读取值。
+// K 是泛型参数，代表任意类型的键。
+// key: 键。
+// 返回值:
+// time.Duration: 剩余生存时间，键没有设置过期时间时为 NoExpiry。
+// bool: 表示键是否存在。
+// error: 操作中发生的任何错误。
+func TTL[K any](key K) (time.Duration, bool, error) {
+	s, err := defaultStore()
+	if err != nil {
+		return 0, false, err
+	}
+	return s.TTL(key)
+}
+
+// Expire 将默认 Store 中键的生存时间设置为 ttl，从现在开始计算。
+// K 是泛型参数，代表任意类型的键。
+// key: 键。
+// ttl: 生存时间。
+// 返回值:
+// bool: 表示键是否存在，不存在时不做任何操作。
+// error: 操作中发生的任何错误。
+func Expire[K any](key K, ttl time.Duration) (bool, error) {
+	s, err := defaultStore()
+	if err != nil {
+		return false, err
+	}
+	return s.Expire(key, ttl)
+}
+
+// ExpireAt 将默认 Store 中键的过期时间设置为 at。at 不晚于当前时间时立即删除键。
+// 参数与返回值同 Expire。
+func ExpireAt[K any](key K, at time.Time) (bool, error) {
+	s, err := defaultStore()
+	if err != nil {
+		return false, err
+	}
+	return s.ExpireAt(key, at)
+}
+
+// Persist 移除默认 Store 中键的过期时间。
+// 参数与返回值同 Expire。
+func Persist[K any](key K) (bool, error) {
+	s, err := defaultStore()
+	if err != nil {
+		return false, err
+	}
+	return s.Persist(key)
+}
+
+// TTL 返回键的剩余生存时间，语义与包级别的 TTL 相同。
+func (s *Store) TTL(key any) (time.Duration, bool, error) {
+	k, err := serializeKey(key)
+	if err != nil {
+		return 0, false, err
+	}
+	var ttl time.Duration
+	exists := true
+	err = s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(k)
+		if err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				exists = false
+				return nil
+			}
+			return err
+		}
+		ttl = remaining(item.ExpiresAt())
+		return nil
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	return ttl, exists, nil
+}
+
+// Expire 将键的生存时间设置为 ttl，语义与包级别的 Expire 相同。
+func (s *Store) Expire(key any, ttl time.Duration) (bool, error) {
+	return s.ExpireAt(key, time.Now().Add(ttl))
+}
+
+// ExpireAt 将键的过期时间设置为 at，语义与包级别的 ExpireAt 相同。
+func (s *Store) ExpireAt(key any, at time.Time) (bool, error) {
+	// Badger 的过期时间是无符号的 Unix 秒，已经过去的时间（尤其是 1970 年之前）无法表示，直接删除。
+	if !at.After(time.Now()) {
+		return s.expireNow(key)
+	}
+	return s.setExpiry(key, uint64(at.Unix()))
+}
+
+// expireNow 删除键，返回值表示键是否存在。
+func (s *Store) expireNow(key any) (bool, error) {
+	k, err := serializeKey(key)
+	if err != nil {
+		return false, err
+	}
+	var exists bool
+	err = s.update(func(txn *badger.Txn) error {
+		exists = false
+		if _, err := txn.Get(k); err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return nil
+			}
+			return err
+		}
+		exists = true
+		return txn.Delete(k)
+	})
+	return exists, err
+}
+
+// Persist 移除键的过期时间，语义与包级别的 Persist 相同。
+func (s *Store) Persist(key any) (bool, error) {
+	return s.setExpiry(key, 0)
+}
+
+// setExpiry 将键的过期时间设置为 expiresAt（Unix 秒，0 表示永不过期）。
+// Badger 无法单独修改元数据，因此会以原始字节重写条目，但不会解码值。
+// 返回值表示键是否存在。
+func (s *Store) setExpiry(key any, expiresAt uint64) (bool, error) {
+	k, err := serializeKey(key)
+	if err != nil {
+		return false, err
+	}
+	return s.rewrite(k, func(*badger.Item) uint64 {
+		return expiresAt
+	})
+}
+
+// rewrite 在读写事务中以原始字节重写键的条目，过期时间由 expiresAt 根据当前条目计算。
+// 事务冲突时按 Options.ConflictRetries 重试。返回值表示键是否存在。
+func (s *Store) rewrite(k []byte, expiresAt func(item *badger.Item) uint64) (bool, error) {
+	var exists bool
+	err := s.update(func(txn *badger.Txn) error {
+		exists = false
+		item, err := txn.Get(k)
+		if err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return nil
+			}
+			return err
+		}
+		exists = true
+		v, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		entry := badger.NewEntry(k, v).WithMeta(item.UserMeta())
+		entry.ExpiresAt = expiresAt(item)
+		return txn.SetEntry(entry)
+	})
+	return exists, err
+}
+
+// remaining 将 Badger 的过期时间（Unix 秒）转换为剩余生存时间。
+func remaining(expiresAt uint64) time.Duration {
+	if expiresAt == 0 {
+		return NoExpiry
+	}
+	d := time.Until(time.Unix(int64(expiresAt), 0))
+	if d < 0 {
+		return 0
+	}
+	return d
+}
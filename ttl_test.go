@@ -0,0 +1,86 @@
+package kv
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTTL_ExpirePersist(t *testing.T) {
+	s, err := Open(Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	if _, exists, err := s.TTL("ttl_missing"); err != nil || exists {
+		t.Fatalf("TTL() on missing key = %v, %v, want false", exists, err)
+	}
+
+	if err = s.Set("ttl_key", "ttl_value"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	ttl, exists, err := s.TTL("ttl_key")
+	if err != nil || !exists || ttl != NoExpiry {
+		t.Fatalf("TTL() = %v, %v, %v, want NoExpiry", ttl, exists, err)
+	}
+
+	if exists, err = s.Expire("ttl_key", time.Hour); err != nil || !exists {
+		t.Fatalf("Expire() = %v, %v, want true", exists, err)
+	}
+	ttl, _, err = s.TTL("ttl_key")
+	if err != nil || ttl <= 59*time.Minute || ttl > time.Hour {
+		t.Errorf("TTL() after Expire = %v, %v, want about 1h", ttl, err)
+	}
+
+	if _, err = s.ExpireAt("ttl_key", time.Now().Add(2*time.Hour)); err != nil {
+		t.Fatalf("ExpireAt() error = %v", err)
+	}
+	ttl, _, err = s.TTL("ttl_key")
+	if err != nil || ttl <= time.Hour {
+		t.Errorf("TTL() after ExpireAt = %v, %v, want about 2h", ttl, err)
+	}
+
+	if _, err = s.Persist("ttl_key"); err != nil {
+		t.Fatalf("Persist() error = %v", err)
+	}
+	ttl, _, err = s.TTL("ttl_key")
+	if err != nil || ttl != NoExpiry {
+		t.Errorf("TTL() after Persist = %v, %v, want NoExpiry", ttl, err)
+	}
+
+	var got string
+	if _, err = s.Get("ttl_key", &got); err != nil || got != "ttl_value" {
+		t.Errorf("Get() = %v, %v, want ttl_value", got, err)
+	}
+
+	if _, err = s.ExpireAt("ttl_key", time.Now().Add(-time.Second)); err != nil {
+		t.Fatalf("ExpireAt() error = %v", err)
+	}
+	if _, exists, err = s.TTL("ttl_key"); err != nil || exists {
+		t.Errorf("TTL() after ExpireAt in the past = %v, %v, want false", exists, err)
+	}
+}
+
+func TestExpireAt_Past(t *testing.T) {
+	s, err := Open(Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	for _, at := range []time.Time{time.Now().Add(-time.Minute), time.Date(1960, 1, 1, 0, 0, 0, 0, time.UTC), {}} {
+		if err = s.Set("old", "v"); err != nil {
+			t.Fatal(err)
+		}
+		if exists, err := s.ExpireAt("old", at); err != nil || !exists {
+			t.Fatalf("ExpireAt(%v) = %v, %v, want true", at, exists, err)
+		}
+		var got string
+		if ok, err := s.Get("old", &got); err != nil || ok {
+			t.Errorf("Get() after ExpireAt(%v) = %v, %v, want the key to be gone", at, ok, err)
+		}
+	}
+	if exists, err := s.ExpireAt("missing", time.Time{}); err != nil || exists {
+		t.Errorf("ExpireAt() on a missing key = %v, %v, want false", exists, err)
+	}
+}
@@ -0,0 +1,184 @@
+package kv
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/dgraph-io/badger/v4/options"
+	"github.com/pkg/errors"
+)
+
+/*
+Badger 的调优选项可以通过以下配置项设置，未设置的配置项使用 Badger 的默认值。
+大小可以是字节数，也可以带 KB、MB、GB 后缀（按 1024 进位），例如 "16MB"。
+
+  - "CACHE SYNC WRITES"：bool，每次写入后同步到磁盘，默认 false。
+  - "CACHE MEMTABLE SIZE"：大小，内存表的大小，默认 64MB。
+  - "CACHE VLOG FILE SIZE"：大小，单个值日志文件的大小，范围为 [1MB, 2GB)，默认 1GB。
+  - "CACHE VALUE THRESHOLD"：大小，超过此大小的值写入值日志，不能超过 1MB，也不能超过内存表的 15%，默认取两者中较小的一个。
+  - "CACHE BLOCK CACHE SIZE"：大小，块缓存的大小，默认 256MB。
+  - "CACHE INDEX CACHE SIZE"：大小，索引缓存的大小，参见 Options.IndexCacheSize。
+  - "CACHE TABLE COMPRESSION"：SST 的压缩算法，取值为 none、snappy 或 zstd，默认 snappy。
+  - "CACHE NUM COMPACTORS"：int，压缩 (compaction) 协程数，不能为 1，默认 4。
+  - "CACHE READ ONLY"：bool，以只读方式打开数据库目录，默认 false。
+  - "CACHE DETECT CONFLICTS"：bool，读写事务是否检测冲突，默认 true。
+*/
+
+// tuningKeys 是映射到 Badger 选项的配置项。
+var tuningKeys = []string{
+	"CACHE SYNC WRITES",
+	"CACHE MEMTABLE SIZE",
+	"CACHE VLOG FILE SIZE",
+	"CACHE VALUE THRESHOLD",
+	"CACHE BLOCK CACHE SIZE",
+	"CACHE INDEX CACHE SIZE",
+	"CACHE TABLE COMPRESSION",
+	"CACHE NUM COMPACTORS",
+	"CACHE READ ONLY",
+	"CACHE DETECT CONFLICTS",
+}
+
+// tableCompressions 是 Options.TableCompression 可以选择的 SST 压缩算法，空字符串表示 Badger 的默认值。
+var tableCompressions = map[string]options.CompressionType{
+	"none":   options.None,
+	"snappy": options.Snappy,
+	"zstd":   options.ZSTD,
+}
+
+const (
+	// minValueLogFileSize 和 maxValueLogFileSize 是 Badger 允许的值日志文件大小范围，不包含上界。
+	minValueLogFileSize = 1 << 20
+	maxValueLogFileSize = 2 << 30
+	// maxValueThreshold 是 Badger 允许的最大 ValueThreshold。
+	maxValueThreshold = 1 << 20
+)
+
+// badgerOptions 校验 opts 并生成打开 Badger 使用的选项。
+func badgerOptions(opts Options) (badger.Options, error) {
+	// 如果 Path 为空，则使用内存数据库。
+	opt := badger.DefaultOptions(opts.Path).WithInMemory(opts.Path == "")
+	// 禁用 Badger 的默认日志记录器，以避免不必要的输出。
+	opt.Logger = nullLogger{}
+
+	for name, size := range map[string]int64{
+		"MemTableSize":     opts.MemTableSize,
+		"ValueLogFileSize": opts.ValueLogFileSize,
+		"ValueThreshold":   opts.ValueThreshold,
+		"BlockCacheSize":   opts.BlockCacheSize,
+		"IndexCacheSize":   opts.IndexCacheSize,
+	} {
+		if size < 0 {
+			return opt, errors.Errorf("%s must not be negative, got %d", name, size)
+		}
+	}
+	if opts.MemTableSize > 0 {
+		opt.MemTableSize = opts.MemTableSize
+	}
+	if opts.ValueLogFileSize > 0 {
+		if opts.ValueLogFileSize < minValueLogFileSize || opts.ValueLogFileSize >= maxValueLogFileSize {
+			return opt, errors.Errorf("ValueLogFileSize must be in [1MB, 2GB), got %d", opts.ValueLogFileSize)
+		}
+		opt.ValueLogFileSize = opts.ValueLogFileSize
+	}
+	if opts.ValueThreshold > 0 {
+		opt.ValueThreshold = opts.ValueThreshold
+	}
+	// Badger 的事务按内存表的 15% 分批，更大的值无法写入。没有指定时，默认值随内存表缩小。
+	limit := min(maxValueThreshold, opt.MemTableSize*15/100)
+	if opts.ValueThreshold == 0 {
+		opt.ValueThreshold = min(opt.ValueThreshold, limit)
+	}
+	if opt.ValueThreshold > limit {
+		return opt, errors.Errorf("ValueThreshold %d exceeds %d (1MB and 15%% of MemTableSize %d)",
+			opt.ValueThreshold, limit, opt.MemTableSize)
+	}
+	if opts.BlockCacheSize > 0 {
+		opt.BlockCacheSize = opts.BlockCacheSize
+	}
+	if opts.TableCompression != "" {
+		c, ok := tableCompressions[opts.TableCompression]
+		if !ok {
+			return opt, errors.Errorf("unknown TableCompression %q", opts.TableCompression)
+		}
+		opt.Compression = c
+	}
+	switch {
+	case opts.NumCompactors < 0 || opts.NumCompactors == 1:
+		return opt, errors.Errorf("NumCompactors must be 0 (default) or at least 2, got %d", opts.NumCompactors)
+	case opts.NumCompactors > 1:
+		opt.NumCompactors = opts.NumCompactors
+	}
+	if opts.ReadOnly && opts.Path == "" {
+		return opt, errors.New("ReadOnly requires a Path")
+	}
+	opt.ReadOnly = opts.ReadOnly
+	opt.SyncWrites = opts.SyncWrites
+	opt.DetectConflicts = !opts.DisableConflictDetection
+	return withEncryption(opt, opts), nil
+}
+
+// applyTuning 解析调优配置项的原始值 values 并设置到 opts 上，values 的键是配置项名称。
+func applyTuning(opts *Options, values map[string]string) error {
+	for key, value := range values {
+		var err error
+		switch key {
+		case "CACHE SYNC WRITES":
+			opts.SyncWrites, err = strconv.ParseBool(value)
+		case "CACHE MEMTABLE SIZE":
+			opts.MemTableSize, err = parseSize(value)
+		case "CACHE VLOG FILE SIZE":
+			opts.ValueLogFileSize, err = parseSize(value)
+		case "CACHE VALUE THRESHOLD":
+			opts.ValueThreshold, err = parseSize(value)
+		case "CACHE BLOCK CACHE SIZE":
+			opts.BlockCacheSize, err = parseSize(value)
+		case "CACHE INDEX CACHE SIZE":
+			opts.IndexCacheSize, err = parseSize(value)
+		case "CACHE TABLE COMPRESSION":
+			opts.TableCompression = strings.ToLower(value)
+			if _, ok := tableCompressions[opts.TableCompression]; !ok {
+				err = errors.New("must be none, snappy or zstd")
+			}
+		case "CACHE NUM COMPACTORS":
+			opts.NumCompactors, err = strconv.Atoi(value)
+		case "CACHE READ ONLY":
+			opts.ReadOnly, err = strconv.ParseBool(value)
+		case "CACHE DETECT CONFLICTS":
+			var detect bool
+			detect, err = strconv.ParseBool(value)
+			opts.DisableConflictDetection = !detect
+		}
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s %q", key, value)
+		}
+	}
+	return nil
+}
+
+// parseSize 解析字节数，支持 KB、MB、GB 后缀（不区分大小写，按 1024 进位）。
+func parseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	unit := int64(1)
+	for _, u := range []struct {
+		suffix string
+		size   int64
+	}{{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30}, {"B", 1}} {
+		if strings.HasSuffix(s, u.suffix) {
+			s, unit = strings.TrimSpace(strings.TrimSuffix(s, u.suffix)), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, errors.New("not a size")
+	}
+	if n < 0 {
+		return 0, errors.New("size must not be negative")
+	}
+	if n > math.MaxInt64/unit {
+		return 0, errors.New("size overflows int64")
+	}
+	return n * unit, nil
+}
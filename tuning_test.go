@@ -0,0 +1,104 @@
+package kv
+
+import (
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	for in, want := range map[string]int64{"4096": 4096, "16MB": 16 << 20, "512 kb": 512 << 10, "1GB": 1 << 30, "8B": 8, "8589934591GB": 8589934591 << 30} {
+		if got, err := parseSize(in); err != nil || got != want {
+			t.Errorf("parseSize(%q) = %d, %v, want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "MB", "1.5GB", "-1", "ten", "9007199254740992KB", "8589934592GB", "9223372036854775808"} {
+		if _, err := parseSize(in); err == nil {
+			t.Errorf("parseSize(%q) should fail", in)
+		}
+	}
+}
+
+func TestApplyTuning(t *testing.T) {
+	var opts Options
+	if err := applyTuning(&opts, map[string]string{
+		"CACHE SYNC WRITES":       "true",
+		"CACHE MEMTABLE SIZE":     "8MB",
+		"CACHE TABLE COMPRESSION": "ZSTD",
+		"CACHE NUM COMPACTORS":    "2",
+		"CACHE DETECT CONFLICTS":  "false",
+	}); err != nil {
+		t.Fatalf("applyTuning() error = %v", err)
+	}
+	if !opts.SyncWrites || opts.MemTableSize != 8<<20 || opts.TableCompression != "zstd" ||
+		opts.NumCompactors != 2 || !opts.DisableConflictDetection {
+		t.Errorf("applyTuning() = %+v", opts)
+	}
+
+	for key, value := range map[string]string{
+		"CACHE SYNC WRITES":       "sometimes",
+		"CACHE MEMTABLE SIZE":     "big",
+		"CACHE TABLE COMPRESSION": "lz4",
+		"CACHE NUM COMPACTORS":    "two",
+	} {
+		if err := applyTuning(&Options{}, map[string]string{key: value}); err == nil {
+			t.Errorf("applyTuning(%s = %q) should fail", key, value)
+		}
+	}
+}
+
+func TestBadgerOptions_Validation(t *testing.T) {
+	for name, opts := range map[string]Options{
+		"negative size":       {BlockCacheSize: -1},
+		"small vlog file":     {ValueLogFileSize: 1 << 10},
+		"huge vlog file":      {ValueLogFileSize: 2 << 30},
+		"threshold over 1MB":  {ValueThreshold: 2 << 20},
+		"threshold over 15%":  {MemTableSize: 1 << 20, ValueThreshold: 512 << 10},
+		"one compactor":       {NumCompactors: 1},
+		"unknown compression": {TableCompression: "lz4"},
+		"read-only in memory": {ReadOnly: true},
+	} {
+		if _, err := badgerOptions(opts); err == nil {
+			t.Errorf("%s: badgerOptions() should fail", name)
+		}
+	}
+
+	// A small memtable lowers the default value threshold instead of failing.
+	opt, err := badgerOptions(Options{MemTableSize: 1 << 20})
+	if err != nil || opt.ValueThreshold > (1<<20)*15/100 {
+		t.Errorf("badgerOptions() = %d, %v, want a threshold within 15%% of the memtable", opt.ValueThreshold, err)
+	}
+}
+
+func TestOpen_Tuned(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(Options{
+		Path:             dir,
+		SyncWrites:       true,
+		MemTableSize:     4 << 20,
+		ValueLogFileSize: 16 << 20,
+		BlockCacheSize:   8 << 20,
+		TableCompression: "zstd",
+		NumCompactors:    2,
+	})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if err = s.Set("k", "v"); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ro, err := Open(Options{Path: dir, ReadOnly: true})
+	if err != nil {
+		t.Fatalf("Open(ReadOnly) error = %v", err)
+	}
+	defer func() { _ = ro.Close() }()
+	var got string
+	if ok, err := ro.Get("k", &got); err != nil || !ok || got != "v" {
+		t.Errorf("Get() = %q, %v, %v, want v", got, ok, err)
+	}
+	if err = ro.Set("k", "w"); err == nil {
+		t.Error("Set() on a read-only store should fail")
+	}
+}
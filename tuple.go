@@ -0,0 +1,262 @@
+package kv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/bits"
+	"reflect"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+/*
+Tuple 实现了类似 FoundationDB tuple layer 的复合键编码。
+每个元素以一个类型码开头，字符串和字节切片对 0x00 转义并以 0x00 结尾，
+整数按最短字节数编码，浮点数使用与 OrderedFloat 键相同的保序转换。
+编码结果是自定界的，并且字节序与元组的字典序一致，
+因此任意前缀元组的编码恰好是更长元组编码的字节前缀，可直接用于前缀扫描和范围查询。
+*/
+
+// Tuple 是一个由多个元素组成的复合键。
+// 支持的元素类型: nil、string、[]byte、有符号与无符号整数、float32、float64、bool、time.Time。
+type Tuple []any
+
+// 元组元素的类型码，数值越小的类型排序越靠前。
+const (
+	tupleNil     byte = 0x00
+	tupleBytes   byte = 0x01
+	tupleString  byte = 0x02
+	tupleIntZero byte = 0x14
+	tupleFloat32 byte = 0x20
+	tupleFloat64 byte = 0x21
+	tupleFalse   byte = 0x26
+	tupleTrue    byte = 0x27
+	tupleTime    byte = 0x40
+)
+
+// tupleEscape 是字节串中 0x00 之后追加的转义字节。
+const tupleEscape byte = 0xFF
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Pack 将元组编码为保序、自定界的字节切片。
+// 返回值:
+// []byte: 编码后的字节切片。
+// error: 遇到不支持的元素类型时返回错误。
+func (t Tuple) Pack() ([]byte, error) {
+	var buf bytes.Buffer
+	for i, elem := range t {
+		if err := packElem(&buf, elem); err != nil {
+			return nil, errors.Wrapf(err, "tuple element %d", i)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// packElem 将单个元素编码后写入 buf。
+func packElem(buf *bytes.Buffer, elem any) error {
+	if elem == nil {
+		buf.WriteByte(tupleNil)
+		return nil
+	}
+	v := reflect.ValueOf(elem)
+	if v.Type() == timeType {
+		// time.Time 编码为翻转符号位的 Unix 秒数(8) 加纳秒数(4)，覆盖 time.Time 的全部范围并保持顺序。
+		tm := v.Interface().(time.Time)
+		buf.WriteByte(tupleTime)
+		var b [12]byte
+		binary.BigEndian.PutUint64(b[:8], uint64(tm.Unix())^(1<<63))
+		binary.BigEndian.PutUint32(b[8:], uint32(tm.Nanosecond()))
+		buf.Write(b[:])
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		buf.WriteByte(tupleString)
+		packBytes(buf, []byte(v.String()))
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			return errors.Errorf("unsupported type %s", v.Type())
+		}
+		buf.WriteByte(tupleBytes)
+		packBytes(buf, v.Bytes())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		packInt(buf, v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		packUint(buf, v.Uint())
+	case reflect.Float32:
+		buf.WriteByte(tupleFloat32)
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, float32Bits(float32(v.Float())))
+		orderBytes(b, reflect.Float32, true)
+		buf.Write(b)
+	case reflect.Float64:
+		buf.WriteByte(tupleFloat64)
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, float64Bits(v.Float()))
+		orderBytes(b, reflect.Float64, true)
+		buf.Write(b)
+	case reflect.Bool:
+		if v.Bool() {
+			buf.WriteByte(tupleTrue)
+		} else {
+			buf.WriteByte(tupleFalse)
+		}
+	default:
+		return errors.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// packBytes 写入转义后的字节串，并以 0x00 结尾。
+func packBytes(buf *bytes.Buffer, b []byte) {
+	for _, c := range b {
+		buf.WriteByte(c)
+		if c == 0x00 {
+			buf.WriteByte(tupleEscape)
+		}
+	}
+	buf.WriteByte(0x00)
+}
+
+// packUint 以最短的大端字节编码非负整数，类型码为 0x14 加上字节数。
+func packUint(buf *bytes.Buffer, n uint64) {
+	if n == 0 {
+		buf.WriteByte(tupleIntZero)
+		return
+	}
+	size := (bits.Len64(n) + 7) / 8
+	buf.WriteByte(tupleIntZero + byte(size))
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], n)
+	buf.Write(b[8-size:])
+}
+
+// packInt 编码有符号整数。负数的类型码为 0x14 减去字节数，内容为绝对值的反码。
+func packInt(buf *bytes.Buffer, n int64) {
+	if n >= 0 {
+		packUint(buf, uint64(n))
+		return
+	}
+	u := uint64(-n)
+	size := (bits.Len64(u) + 7) / 8
+	buf.WriteByte(tupleIntZero - byte(size))
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], ^u)
+	buf.Write(b[8-size:])
+}
+
+// Unpack 将 Pack 生成的字节切片解码为元组。
+// 整数解码为 int64，超出 int64 范围的正整数解码为 uint64；
+// time.Time 解码为本地时区的时间，不保留原始时区与单调时钟读数。
+// data: 需要被解码的字节切片。
+// 返回值:
+// Tuple: 解码后的元组。
+// error: 数据格式错误时返回错误。
+func Unpack(data []byte) (Tuple, error) {
+	var t Tuple
+	for len(data) > 0 {
+		elem, rest, err := unpackElem(data)
+		if err != nil {
+			return nil, err
+		}
+		t = append(t, elem)
+		data = rest
+	}
+	return t, nil
+}
+
+// unpackElem 解码一个元素，并返回剩余的字节。
+func unpackElem(data []byte) (any, []byte, error) {
+	code := data[0]
+	data = data[1:]
+	switch {
+	case code == tupleNil:
+		return nil, data, nil
+	case code == tupleBytes:
+		b, rest, err := unpackBytes(data)
+		return b, rest, err
+	case code == tupleString:
+		b, rest, err := unpackBytes(data)
+		return string(b), rest, err
+	case code == tupleIntZero:
+		return int64(0), data, nil
+	case code > tupleIntZero && code <= tupleIntZero+8:
+		size := int(code - tupleIntZero)
+		if len(data) < size {
+			return nil, nil, errors.New("insufficient data for tuple int")
+		}
+		var b [8]byte
+		copy(b[8-size:], data[:size])
+		u := binary.BigEndian.Uint64(b[:])
+		if u > 1<<63-1 {
+			return u, data[size:], nil
+		}
+		return int64(u), data[size:], nil
+	case code >= tupleIntZero-8 && code < tupleIntZero:
+		size := int(tupleIntZero - code)
+		if len(data) < size {
+			return nil, nil, errors.New("insufficient data for tuple int")
+		}
+		b := [8]byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+		copy(b[8-size:], data[:size])
+		u := ^binary.BigEndian.Uint64(b[:])
+		if u > 1<<63 {
+			return nil, nil, errors.New("tuple int overflows int64")
+		}
+		return -int64(u), data[size:], nil
+	case code == tupleFloat32:
+		if len(data) < 4 {
+			return nil, nil, errors.New("insufficient data for tuple float32")
+		}
+		b := append([]byte(nil), data[:4]...)
+		orderBytes(b, reflect.Float32, false)
+		return float32FromBits(binary.BigEndian.Uint32(b)), data[4:], nil
+	case code == tupleFloat64:
+		if len(data) < 8 {
+			return nil, nil, errors.New("insufficient data for tuple float64")
+		}
+		b := append([]byte(nil), data[:8]...)
+		orderBytes(b, reflect.Float64, false)
+		return float64FromBits(binary.BigEndian.Uint64(b)), data[8:], nil
+	case code == tupleFalse:
+		return false, data, nil
+	case code == tupleTrue:
+		return true, data, nil
+	case code == tupleTime:
+		if len(data) < 12 {
+			return nil, nil, errors.New("insufficient data for tuple time")
+		}
+		sec := int64(binary.BigEndian.Uint64(data[:8]) ^ (1 << 63))
+		nsec := binary.BigEndian.Uint32(data[8:12])
+		if nsec >= 1e9 {
+			return nil, nil, errors.New("invalid nanoseconds in tuple time")
+		}
+		return time.Unix(sec, int64(nsec)), data[12:], nil
+	default:
+		return nil, nil, errors.Errorf("unknown tuple type code 0x%02x", code)
+	}
+}
+
+// unpackBytes 读取一个转义的字节串，直到未转义的 0x00 为止。
+func unpackBytes(data []byte) ([]byte, []byte, error) {
+	var out []byte
+	for i := 0; i < len(data); i++ {
+		if data[i] != 0x00 {
+			out = append(out, data[i])
+			continue
+		}
+		if i+1 < len(data) && data[i+1] == tupleEscape {
+			out = append(out, 0x00)
+			i++
+			continue
+		}
+		if out == nil {
+			out = []byte{}
+		}
+		return out, data[i+1:], nil
+	}
+	return nil, nil, errors.New("unterminated tuple bytes")
+}
@@ -0,0 +1,116 @@
+package kv
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTuple_RoundTrip(t *testing.T) {
+	now := time.Unix(1700000000, 123)
+	in := Tuple{"tenant\x00a", []byte{0, 1, 2}, int64(-42), uint64(math.MaxUint64), 3.5, float32(-1.25), true, false, nil, now}
+	data, err := in.Pack()
+	if err != nil {
+		t.Fatalf("Pack() error = %v", err)
+	}
+	got, err := Unpack(data)
+	if err != nil {
+		t.Fatalf("Unpack() error = %v", err)
+	}
+	want := Tuple{"tenant\x00a", []byte{0, 1, 2}, int64(-42), uint64(math.MaxUint64), 3.5, float32(-1.25), true, false, nil, now}
+	if len(got) != len(want) {
+		t.Fatalf("Unpack() len = %d, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if w, ok := want[i].(time.Time); ok {
+			if !got[i].(time.Time).Equal(w) {
+				t.Errorf("Unpack()[%d] = %v, want %v", i, got[i], w)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("Unpack()[%d] = %#v, want %#v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestTuple_Order(t *testing.T) {
+	tuples := []Tuple{
+		{"a", int64(math.MinInt64)},
+		{"a", -300},
+		{"a", -1},
+		{"a", 0},
+		{"a", 1},
+		{"a", 300},
+		{"a", uint64(math.MaxUint64)},
+		{"a\x00"},
+		{"ab"},
+		{"b", "x"},
+	}
+	var prev []byte
+	for _, tuple := range tuples {
+		data, err := tuple.Pack()
+		if err != nil {
+			t.Fatalf("Pack() error = %v", err)
+		}
+		if prev != nil && bytes.Compare(prev, data) >= 0 {
+			t.Errorf("Pack(%v) does not sort after its predecessor", tuple)
+		}
+		prev = data
+	}
+}
+
+func TestTuple_Prefix(t *testing.T) {
+	prefix, _ := Tuple{"tenant", 7}.Pack()
+	full, _ := Tuple{"tenant", 7, time.Now()}.Pack()
+	other, _ := Tuple{"tenant", 70}.Pack()
+	if !bytes.HasPrefix(full, prefix) {
+		t.Errorf("packed tuple should start with its packed prefix")
+	}
+	if bytes.HasPrefix(other, prefix) {
+		t.Errorf("packed tuple should not match a different element as prefix")
+	}
+}
+
+func TestTuple_AsKey(t *testing.T) {
+	key := Tuple{"tenant", 7, "user"}
+	if err := Set(key, "tuple_value"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	got, exists, err := Get[Tuple, string](Tuple{"tenant", int64(7), "user"})
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if !exists || got != "tuple_value" {
+		t.Errorf("Get() = %v, %v, want tuple_value, true", got, exists)
+	}
+}
+
+func TestTuple_TimeRange(t *testing.T) {
+	times := []time.Time{
+		{},
+		time.Date(1000, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(1969, 12, 31, 23, 59, 59, 999999999, time.UTC),
+		time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2000, 1, 1, 0, 0, 0, 1, time.UTC),
+		time.Date(2300, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	var prev []byte
+	for _, tm := range times {
+		packed, err := Tuple{tm}.Pack()
+		if err != nil {
+			t.Fatalf("Pack(%v) error = %v", tm, err)
+		}
+		if prev != nil && bytes.Compare(prev, packed) >= 0 {
+			t.Errorf("Pack(%v) does not sort after its predecessor", tm)
+		}
+		prev = packed
+
+		got, err := Unpack(packed)
+		if err != nil || !got[0].(time.Time).Equal(tm) {
+			t.Errorf("Unpack(Pack(%v)) = %v, %v", tm, got, err)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package kv
+
+import (
+	"github.com/dgraph-io/badger/v4"
+	"github.com/pkg/errors"
+)
+
+// Tx 是一个事务，在 Update 或 View 的回调中使用。
+// 通过 TxGet、TxSet、TxDel 和 TxExists 读写数据，序列化方式与包级别函数相同。
+// Tx 只在回调执行期间有效。
+type Tx struct {
+	txn *badger.Txn
+	s   *Store
+}
+
+// Update 在默认 Store 上执行一个读写事务。
+// fn 返回 nil 时提交事务，返回错误时丢弃事务。
+// 提交遇到 badger.ErrConflict 时会重新执行 fn，次数由 Options.ConflictRetries 控制，
+// 因此 fn 可能被调用多次，不应有事务之外的副作用。
+func Update(fn func(tx *Tx) error) error {
+	s, err := defaultStore()
+	if err != nil {
+		return err
+	}
+	return s.Update(fn)
+}
+
+// View 在默认 Store 上执行一个只读事务。
+func View(fn func(tx *Tx) error) error {
+	s, err := defaultStore()
+	if err != nil {
+		return err
+	}
+	return s.View(fn)
+}
+
+// Update 在 Store 上执行一个读写事务，语义与包级别的 Update 相同。
+func (s *Store) Update(fn func(tx *Tx) error) error {
+	return s.update(func(txn *badger.Txn) error {
+		return fn(&Tx{txn: txn, s: s})
+	})
+}
+
+// View 在 Store 上执行一个只读事务。
+func (s *Store) View(fn func(tx *Tx) error) error {
+	return s.db.View(func(txn *badger.Txn) error {
+		return fn(&Tx{txn: txn, s: s})
+	})
+}
+
+// update 执行一个读写事务，并在遇到 badger.ErrConflict 时重试。
+func (s *Store) update(fn func(txn *badger.Txn) error) error {
+	for i := 0; ; i++ {
+		err := s.db.Update(fn)
+		if !errors.Is(err, badger.ErrConflict) || i >= s.opts.ConflictRetries {
+			return err
+		}
+	}
+}
+
+// TxGet 在事务中获取一个值。
+// K, V 是泛型参数，代表任意类型的键和值。
+// tx: 事务。
+// key: 键。
+// 返回值:
+// V: 获取到的值。
+// bool: 表示键是否存在。
+// error: 操作中发生的任何错误。
+func TxGet[K, V any](tx *Tx, key K) (V, bool, error) {
+	var value V
+	k, err := serializeKey(key)
+	if err != nil {
+		return value, false, err
+	}
+	item, err := tx.txn.Get(k)
+	if err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return value, false, nil
+		}
+		return value, false, err
+	}
+	if err = item.Value(func(val []byte) error {
+		if value, err = unmarshalValue[V](tx.s, item, val); err != nil {
+			return err
+		}
+		return nil
+	}); err != nil {
+		return value, true, err
+	}
+	return value, true, nil
+}
+
+// TxSet 在事务中写入一个键值对，可选择性地设置生存时间 (TTL)。
+// K, V 是泛型参数，代表任意类型的键和值。
+// tx: 事务，必须是读写事务。
+// key: 键。
+// value: 值。
+// ttl: 可选参数，生命周期，单位毫秒。
+func TxSet[K, V any](tx *Tx, key K, value V, ttl ...int64) error {
+	k, err := serializeKey(key)
+	if err != nil {
+		return err
+	}
+	v, meta, err := tx.s.marshal(k, value)
+	if err != nil {
+		return err
+	}
+	return tx.txn.SetEntry(newEntry(k, v, meta, ttl))
+}
+
+// TxDel 在事务中删除一个键。
+// K 是泛型参数，代表任意类型的键。
+// tx: 事务，必须是读写事务。
+// key: 要删除的键。
+func TxDel[K any](tx *Tx, key K) error {
+	k, err := serializeKey(key)
+	if err != nil {
+		return err
+	}
+	return tx.txn.Delete(k)
+}
+
+// TxExists 在事务中检查一个键是否存在，不读取值。
+// K 是泛型参数，代表任意类型的键。
+// tx: 事务。
+// key: 要检查的键。
+func TxExists[K any](tx *Tx, key K) (bool, error) {
+	k, err := serializeKey(key)
+	if err != nil {
+		return false, err
+	}
+	if _, err = tx.txn.Get(k); err != nil {
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
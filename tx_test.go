@@ -0,0 +1,102 @@
+package kv
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+func TestUpdate_Transfer(t *testing.T) {
+	s, err := Open(Options{ConflictRetries: 1000})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	if err = s.Update(func(tx *Tx) error {
+		if err := TxSet(tx, "balance:a", int64(100)); err != nil {
+			return err
+		}
+		return TxSet(tx, "balance:b", int64(0))
+	}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.Update(func(tx *Tx) error {
+				a, _, err := TxGet[string, int64](tx, "balance:a")
+				if err != nil {
+					return err
+				}
+				b, _, err := TxGet[string, int64](tx, "balance:b")
+				if err != nil {
+					return err
+				}
+				if err = TxSet(tx, "balance:a", a-1); err != nil {
+					return err
+				}
+				return TxSet(tx, "balance:b", b+1)
+			}); err != nil {
+				t.Errorf("Update() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err = s.View(func(tx *Tx) error {
+		a, _, err := TxGet[string, int64](tx, "balance:a")
+		if err != nil {
+			return err
+		}
+		b, _, err := TxGet[string, int64](tx, "balance:b")
+		if err != nil {
+			return err
+		}
+		if a != 80 || b != 20 {
+			t.Errorf("balances = %d, %d, want 80, 20", a, b)
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("View() error = %v", err)
+	}
+}
+
+func TestUpdate_Rollback(t *testing.T) {
+	s, err := Open(Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	errAbort := errors.New("abort")
+	if err = s.Update(func(tx *Tx) error {
+		if err := TxSet(tx, "tx_key", "tx_value"); err != nil {
+			return err
+		}
+		return errAbort
+	}); !errors.Is(err, errAbort) {
+		t.Fatalf("Update() error = %v, want %v", err, errAbort)
+	}
+
+	if err = s.View(func(tx *Tx) error {
+		exists, err := TxExists(tx, "tx_key")
+		if err != nil {
+			return err
+		}
+		if exists {
+			t.Errorf("TxExists() key should not exist after rollback")
+		}
+		if err = TxDel(tx, "tx_key"); !errors.Is(err, badger.ErrReadOnlyTxn) {
+			t.Errorf("TxDel() in View error = %v, want ErrReadOnlyTxn", err)
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("View() error = %v", err)
+	}
+}